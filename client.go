@@ -1,10 +1,15 @@
 package mid
 
 import (
-	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"net"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/rs/zerolog"
 )
@@ -18,53 +23,138 @@ const (
 	powerMACSTighteningBoltSub = "0107"
 )
 
+var (
+	ErrClientClosed   = errors.New("mid client closed")
+	ErrConnectionLost = errors.New("mid connection lost")
+	ErrKeepAlive      = errors.New("mid keep alive failed")
+	ErrRestore        = errors.New("mid session restore failed")
+)
+
 type Client struct {
-	conn      net.Conn
-	feedback  *Publisher
-	chans     sync.Map
-	semaphore chan struct{}
-	done      chan struct{}
-	logger    zerolog.Logger
+	addr       string
+	conn       net.Conn
+	dropped    chan struct{}
+	chans      sync.Map
+	subs       sync.Map
+	started    atomic.Bool
+	connected  atomic.Bool
+	lastSent   atomic.Int64
+	cause      error
+	sequence   bool
+	linkLevel  atomic.Bool
+	seq        int
+	rseq       int
+	unacked    map[int]*linkFrame
+	semaphore  chan struct{}
+	done       chan struct{}
+	closed     chan struct{}
+	once       sync.Once
+	mu         sync.Mutex
+	waiter     *waiter
+	held       chan struct{}
+	wmu        sync.Mutex
+	timeout    time.Duration
+	backoff    time.Duration
+	maxBackoff time.Duration
+
+	keepAlive        time.Duration
+	keepAliveTimeout time.Duration
+	stateHandler     func(ConnectionEvent)
+
+	unsolicitedHandler func(MID)
+	codec              []CodecOption
+
+	logger zerolog.Logger
 }
 
-func NewClient(host string, port string, logger zerolog.Logger) (*Client, error) {
-	tcpAddr, err := net.ResolveTCPAddr("tcp", fmt.Sprintf("%s:%s", host, port))
-	if err != nil {
-		return nil, err
-	}
-	conn, err := net.DialTCP("tcp", nil, tcpAddr)
-	if err != nil {
-		return nil, err
-	}
-	err = conn.SetKeepAlive(true)
+func NewClient(host string, port string, logger zerolog.Logger, opts ...Option) (*Client, error) {
+	addr := net.JoinHostPort(host, port)
+	conn, err := dial(addr)
 	if err != nil {
 		return nil, err
 	}
 	cln := &Client{
+		addr:      addr,
 		conn:      conn,
-		feedback:  NewPublisher(),
+		dropped:   make(chan struct{}),
 		chans:     sync.Map{},
+		subs:      sync.Map{},
 		semaphore: make(chan struct{}, 1),
 		done:      make(chan struct{}),
+		closed:    make(chan struct{}),
 		logger:    logger,
 	}
+	for _, opt := range opts {
+		opt(cln)
+	}
+	cln.connected.Store(true)
 	go cln.read()
+	if cln.keepAlive > 0 {
+		go cln.keepAliveLoop()
+	}
 	return cln, nil
 }
 
+func dial(addr string) (net.Conn, error) {
+	tcpAddr, err := net.ResolveTCPAddr("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialTCP("tcp", nil, tcpAddr)
+	if err != nil {
+		return nil, err
+	}
+	err = conn.SetKeepAlive(true)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
 func (c *Client) Close() {
-	close(c.done)
+	c.once.Do(func() {
+		close(c.done)
+		c.disconnect()
+	})
 }
 
 func (c *Client) ApplicationCommunicationStart() error {
+	return c.ApplicationCommunicationStartContext(context.Background())
+}
+
+func (c *Client) ApplicationCommunicationStartContext(ctx context.Context) error {
+	revision := 1
+	if c.sequence {
+		revision = linkRevision
+	}
+	err := c.start(ctx, revision)
+	var refused *MID0004REV001
+	if revision > 1 && errors.As(err, &refused) && refused.ErrorCode == MIDRevisionUnsupported {
+		c.logger.Warn().Msg("Controller does not support sequence numbers")
+		err = c.start(ctx, 1)
+	}
+	if err != nil {
+		return err
+	}
+	c.started.Store(true)
+	return nil
+}
+
+// start sends MID 0001 in the given revision. From revision 6 on, MID 0002
+// tells whether the controller supports sequence numbers, so MID 0001 is
+// sent with sequence number 1 to ask for them.
+func (c *Client) start(ctx context.Context, revision int) error {
 	mid0001 := MID{
 		Header: Header{
-			Length:   20,
 			MID:      1,
-			Revision: 1,
+			Revision: revision,
 		},
 	}
-	if err := c.execCMD(mid0001, func(mid MID) error {
+	if revision >= linkRevision {
+		mid0001.Header.SequenceNumber = 1
+	}
+	return c.execCMD(ctx, mid0001, func(mid MID) error {
 		if mid.Header.MID == 4 {
 			return midErr(mid)
 		}
@@ -72,21 +162,21 @@ func (c *Client) ApplicationCommunicationStart() error {
 			return fmt.Errorf("invalid mid: %d", mid.Header.MID)
 		}
 		return nil
-	}); err != nil {
-		return err
-	}
-	return nil
+	}, 2)
 }
 
 func (c *Client) ApplicationCommunicationStop() error {
+	return c.ApplicationCommunicationStopContext(context.Background())
+}
+
+func (c *Client) ApplicationCommunicationStopContext(ctx context.Context) error {
 	mid0003 := MID{
 		Header: Header{
-			Length:   20,
 			MID:      3,
 			Revision: 1,
 		},
 	}
-	if err := c.execCMD(mid0003, func(mid MID) error {
+	if err := c.execCMD(ctx, mid0003, func(mid MID) error {
 		if mid.Header.MID != 5 {
 			return fmt.Errorf("invalid mid: %d", mid.Header.MID)
 		}
@@ -94,194 +184,277 @@ func (c *Client) ApplicationCommunicationStop() error {
 	}); err != nil {
 		return err
 	}
+	c.started.Store(false)
 	return nil
 }
 
-func (c *Client) JobInfoSubscribe() (<-chan []byte, error) {
-	p := NewPublisher()
-	c.chans.Store(jobInfoSub, p)
+func (c *Client) JobInfoSubscribe(opts ...SubscribeOption) (<-chan []byte, error) {
+	return c.JobInfoSubscribeContext(context.Background(), opts...)
+}
+
+func (c *Client) JobInfoSubscribeContext(ctx context.Context, opts ...SubscribeOption) (<-chan []byte, error) {
 	mid0034 := MID{
 		Header: Header{
-			Length:   20,
 			MID:      34,
 			Revision: 1,
 		},
 	}
-	if err := c.execCMD(mid0034, standartHandler); err != nil {
+	mid0037 := MID{
+		Header: Header{
+			MID:      37,
+			Revision: 1,
+		},
+	}
+	return c.subscribe(ctx, mid0034, mid0037, opts, jobInfoSub)
+}
+
+func (c *Client) JobInfoSubscribeDecoded(opts ...SubscribeOption) (<-chan Message[MID0035REV001], error) {
+	return c.JobInfoSubscribeDecodedContext(context.Background(), opts...)
+}
+
+func (c *Client) JobInfoSubscribeDecodedContext(ctx context.Context, opts ...SubscribeOption) (<-chan Message[MID0035REV001], error) {
+	ch, err := c.JobInfoSubscribeContext(ctx, append(opts, decode)...)
+	if err != nil {
 		return nil, err
 	}
-	return p.Read(), nil
+	return decoded[MID0035REV001](ch, c.confirm(jobInfoSub), c.codec), nil
 }
 
 func (c *Client) JobInfoAcknowledge() error {
+	return c.JobInfoAcknowledgeContext(context.Background())
+}
+
+func (c *Client) JobInfoAcknowledgeContext(ctx context.Context) error {
 	mid0036 := MID{
 		Header: Header{
-			Length:   20,
 			MID:      36,
 			Revision: 1,
 		},
 	}
-	return c.acknowledge(mid0036)
+	return c.acknowledge(ctx, mid0036)
 }
 
 func (c *Client) JobInfoUnsubscribe() error {
+	return c.JobInfoUnsubscribeContext(context.Background())
+}
+
+func (c *Client) JobInfoUnsubscribeContext(ctx context.Context) error {
 	mid0037 := MID{
 		Header: Header{
-			Length:   20,
 			MID:      37,
 			Revision: 1,
 		},
 	}
-	if err := c.execCMD(mid0037, standartHandler); err != nil {
-		return err
-	}
-	return nil
+	return c.unsubscribe(ctx, mid0037, jobInfoSub)
+}
+
+func (c *Client) VehicleIDNumberSubscribe(opts ...SubscribeOption) (<-chan []byte, error) {
+	return c.VehicleIDNumberSubscribeContext(context.Background(), opts...)
 }
 
-func (c *Client) VehicleIDNumberSubscribe() (<-chan []byte, error) {
-	p := NewPublisher()
-	c.chans.Store(vinSub, p)
+func (c *Client) VehicleIDNumberSubscribeContext(ctx context.Context, opts ...SubscribeOption) (<-chan []byte, error) {
 	mid0051 := MID{
 		Header: Header{
-			Length:   20,
 			MID:      51,
 			Revision: 1,
 		},
 	}
-	if err := c.execCMD(mid0051, standartHandler); err != nil {
+	mid0054 := MID{
+		Header: Header{
+			MID:      54,
+			Revision: 1,
+		},
+	}
+	return c.subscribe(ctx, mid0051, mid0054, opts, vinSub)
+}
+
+func (c *Client) VehicleIDNumberSubscribeDecoded(opts ...SubscribeOption) (<-chan Message[MID0052REV001], error) {
+	return c.VehicleIDNumberSubscribeDecodedContext(context.Background(), opts...)
+}
+
+func (c *Client) VehicleIDNumberSubscribeDecodedContext(ctx context.Context, opts ...SubscribeOption) (<-chan Message[MID0052REV001], error) {
+	ch, err := c.VehicleIDNumberSubscribeContext(ctx, append(opts, decode)...)
+	if err != nil {
 		return nil, err
 	}
-	return p.Read(), nil
+	return decoded[MID0052REV001](ch, c.confirm(vinSub), c.codec), nil
 }
 
 func (c *Client) VehicleIDNumberAcknowledge() error {
+	return c.VehicleIDNumberAcknowledgeContext(context.Background())
+}
+
+func (c *Client) VehicleIDNumberAcknowledgeContext(ctx context.Context) error {
 	mid0053 := MID{
 		Header: Header{
-			Length:   20,
 			MID:      53,
 			Revision: 1,
 		},
 	}
-	return c.acknowledge(mid0053)
+	return c.acknowledge(ctx, mid0053)
 }
 
 func (c *Client) VehicleIDNumberUnsubscribe() error {
+	return c.VehicleIDNumberUnsubscribeContext(context.Background())
+}
+
+func (c *Client) VehicleIDNumberUnsubscribeContext(ctx context.Context) error {
 	mid0054 := MID{
 		Header: Header{
-			Length:   20,
 			MID:      54,
 			Revision: 1,
 		},
 	}
-	if err := c.execCMD(mid0054, standartHandler); err != nil {
-		return err
-	}
-	return nil
+	return c.unsubscribe(ctx, mid0054, vinSub)
+}
+
+func (c *Client) LastTighteningResultDataSubscribe(opts ...SubscribeOption) (<-chan []byte, error) {
+	return c.LastTighteningResultDataSubscribeContext(context.Background(), opts...)
 }
 
-func (c *Client) LastTighteningResultDataSubscribe() (<-chan []byte, error) {
-	p := NewPublisher()
-	c.chans.Store(tighteningSub, p)
+func (c *Client) LastTighteningResultDataSubscribeContext(ctx context.Context, opts ...SubscribeOption) (<-chan []byte, error) {
 	mid0060 := MID{
 		Header: Header{
-			Length:   20,
 			MID:      60,
 			Revision: 1,
 		},
 	}
-	if err := c.execCMD(mid0060, standartHandler); err != nil {
+	mid0063 := MID{
+		Header: Header{
+			MID:      63,
+			Revision: 1,
+		},
+	}
+	return c.subscribe(ctx, mid0060, mid0063, opts, tighteningSub)
+}
+
+func (c *Client) LastTighteningResultDataSubscribeDecoded(opts ...SubscribeOption) (<-chan Message[MID0061REV001], error) {
+	return c.LastTighteningResultDataSubscribeDecodedContext(context.Background(), opts...)
+}
+
+func (c *Client) LastTighteningResultDataSubscribeDecodedContext(ctx context.Context, opts ...SubscribeOption) (<-chan Message[MID0061REV001], error) {
+	ch, err := c.LastTighteningResultDataSubscribeContext(ctx, append(opts, decode)...)
+	if err != nil {
 		return nil, err
 	}
-	return p.Read(), nil
+	return decoded[MID0061REV001](ch, c.confirm(tighteningSub), c.codec), nil
 }
 
 func (c *Client) LastTighteningResultDataAcknowledge() error {
+	return c.LastTighteningResultDataAcknowledgeContext(context.Background())
+}
+
+func (c *Client) LastTighteningResultDataAcknowledgeContext(ctx context.Context) error {
 	mid0062 := MID{
 		Header: Header{
-			Length:   20,
 			MID:      62,
 			Revision: 1,
 		},
 	}
-	return c.acknowledge(mid0062)
+	return c.acknowledge(ctx, mid0062)
 }
 
 func (c *Client) LastTighteningResultDataUnsubscribe() error {
+	return c.LastTighteningResultDataUnsubscribeContext(context.Background())
+}
+
+func (c *Client) LastTighteningResultDataUnsubscribeContext(ctx context.Context) error {
 	mid0063 := MID{
 		Header: Header{
-			Length:   20,
 			MID:      63,
 			Revision: 1,
 		},
 	}
-	if err := c.execCMD(mid0063, standartHandler); err != nil {
-		return err
-	}
-	return nil
+	return c.unsubscribe(ctx, mid0063, tighteningSub)
+}
+
+func (c *Client) MultiSpindleResultSubscribe(opts ...SubscribeOption) (<-chan []byte, error) {
+	return c.MultiSpindleResultSubscribeContext(context.Background(), opts...)
 }
 
-func (c *Client) MultiSpindleResultSubscribe() (<-chan []byte, error) {
-	p := NewPublisher()
-	c.chans.Store(multiSpindelSub, p)
+func (c *Client) MultiSpindleResultSubscribeContext(ctx context.Context, opts ...SubscribeOption) (<-chan []byte, error) {
 	mid0100 := MID{
 		Header: Header{
-			Length:   20,
 			MID:      100,
 			Revision: 1,
 		},
 	}
-	if err := c.execCMD(mid0100, standartHandler); err != nil {
+	mid0103 := MID{
+		Header: Header{
+			MID:      103,
+			Revision: 1,
+		},
+	}
+	return c.subscribe(ctx, mid0100, mid0103, opts, multiSpindelSub)
+}
+
+func (c *Client) MultiSpindleResultSubscribeDecoded(opts ...SubscribeOption) (<-chan Message[MID0101REV001], error) {
+	return c.MultiSpindleResultSubscribeDecodedContext(context.Background(), opts...)
+}
+
+func (c *Client) MultiSpindleResultSubscribeDecodedContext(ctx context.Context, opts ...SubscribeOption) (<-chan Message[MID0101REV001], error) {
+	ch, err := c.MultiSpindleResultSubscribeContext(ctx, append(opts, decode)...)
+	if err != nil {
 		return nil, err
 	}
-	return p.Read(), nil
+	return decoded[MID0101REV001](ch, c.confirm(multiSpindelSub), c.codec), nil
 }
 
 func (c *Client) MultiSpindleResultAcknowledge() error {
+	return c.MultiSpindleResultAcknowledgeContext(context.Background())
+}
+
+func (c *Client) MultiSpindleResultAcknowledgeContext(ctx context.Context) error {
 	mid0102 := MID{
 		Header: Header{
-			Length:   20,
 			MID:      102,
 			Revision: 1,
 		},
 	}
-	return c.acknowledge(mid0102)
+	return c.acknowledge(ctx, mid0102)
 }
 
 func (c *Client) MultiSpindleResultUnsubscribe() error {
+	return c.MultiSpindleResultUnsubscribeContext(context.Background())
+}
+
+func (c *Client) MultiSpindleResultUnsubscribeContext(ctx context.Context) error {
 	mid0103 := MID{
 		Header: Header{
-			Length:   20,
 			MID:      103,
 			Revision: 1,
 		},
 	}
-	if err := c.execCMD(mid0103, standartHandler); err != nil {
-		return err
-	}
-	return nil
+	return c.unsubscribe(ctx, mid0103, multiSpindelSub)
 }
 
-func (c *Client) LastPowerMACSTighteningResultDataSubscribe() (<-chan []byte, error) {
-	p := NewPublisher()
-	c.chans.Store(powerMACSTighteningSub, p)
-	c.chans.Store(powerMACSTighteningBoltSub, p)
+func (c *Client) LastPowerMACSTighteningResultDataSubscribe(opts ...SubscribeOption) (<-chan []byte, error) {
+	return c.LastPowerMACSTighteningResultDataSubscribeContext(context.Background(), opts...)
+}
+
+func (c *Client) LastPowerMACSTighteningResultDataSubscribeContext(ctx context.Context, opts ...SubscribeOption) (<-chan []byte, error) {
 	mid0105 := MID{
 		Header: Header{
-			Length:   20,
 			MID:      105,
 			Revision: 1,
 		},
 	}
-	if err := c.execCMD(mid0105, standartHandler); err != nil {
-		return nil, err
+	mid0109 := MID{
+		Header: Header{
+			MID:      109,
+			Revision: 1,
+		},
 	}
-	return p.Read(), nil
+	return c.subscribe(ctx, mid0105, mid0109, opts, powerMACSTighteningSub, powerMACSTighteningBoltSub)
 }
 
 func (c *Client) LastPowerMACSTighteningResultDataAcknowledge(withBoltData bool) error {
+	return c.LastPowerMACSTighteningResultDataAcknowledgeContext(context.Background(), withBoltData)
+}
+
+func (c *Client) LastPowerMACSTighteningResultDataAcknowledgeContext(ctx context.Context, withBoltData bool) error {
 	mid0108 := MID{
 		Header: Header{
-			Length:   21,
 			MID:      108,
 			Revision: 1,
 		},
@@ -290,37 +463,40 @@ func (c *Client) LastPowerMACSTighteningResultDataAcknowledge(withBoltData bool)
 	if withBoltData {
 		mid0108.Data = []byte("1")
 	}
-	return c.acknowledge(mid0108)
+	return c.acknowledge(ctx, mid0108)
 }
 
 func (c *Client) LastPowerMACSTighteningResultDataUnsubscribe() error {
+	return c.LastPowerMACSTighteningResultDataUnsubscribeContext(context.Background())
+}
+
+func (c *Client) LastPowerMACSTighteningResultDataUnsubscribeContext(ctx context.Context) error {
 	mid0109 := MID{
 		Header: Header{
-			Length:   20,
 			MID:      109,
 			Revision: 1,
 		},
 	}
-	if err := c.execCMD(mid0109, standartHandler); err != nil {
-		return err
-	}
-	return nil
+	return c.unsubscribe(ctx, mid0109, powerMACSTighteningSub, powerMACSTighteningBoltSub)
 }
 
 func (c *Client) KeepAliveMessage() error {
+	return c.KeepAliveMessageContext(context.Background())
+}
+
+func (c *Client) KeepAliveMessageContext(ctx context.Context) error {
 	mid9999 := MID{
 		Header: Header{
-			Length:   20,
 			MID:      9999,
 			Revision: 1,
 		},
 	}
-	if err := c.execCMD(mid9999, func(mid MID) error {
+	if err := c.execCMD(ctx, mid9999, func(mid MID) error {
 		if mid.Header.MID != 9999 {
 			return fmt.Errorf("invalid mid: %d", mid.Header.MID)
 		}
 		return nil
-	}); err != nil {
+	}, 9999); err != nil {
 		return err
 	}
 	return nil
@@ -333,62 +509,372 @@ func (c *Client) read() {
 			return
 		}
 		c.chans.Range(func(key, value any) bool {
-			p, ok := value.(*Publisher)
+			s, ok := value.(*subscription)
 			if ok {
-				p.Close()
+				s.close()
 				c.chans.Delete(key)
 			}
 			return true
 		})
-		c.feedback.Close()
-		c.conn.Close()
+		close(c.closed)
+		c.disconnect()
+		c.notify(StateClosed, nil)
 	}()
 	for {
+		err := c.receive()
+		c.disconnect()
+		c.stopLink()
+		c.fail()
 		select {
 		case <-c.done:
 			return
 		default:
-			data, err := bufio.NewReader(c.conn).ReadBytes('\x00')
+		}
+		c.notify(StateDisconnected, c.takeCause(err))
+		if c.backoff <= 0 || !c.redial() {
+			return
+		}
+		held := c.hold()
+		c.connected.Store(true)
+		c.notify(StateConnected, nil)
+		go c.restore(held)
+	}
+}
+
+func (c *Client) receive() error {
+	c.mu.Lock()
+	dropped := c.dropped
+	c.mu.Unlock()
+	dec := NewDecoder(c.conn)
+	asm := NewAssembler()
+	for {
+		select {
+		case <-c.done:
+			return ErrClientClosed
+		default:
+			data, err := dec.ReadFrame()
+			var ferr *FrameError
+			if errors.As(err, &ferr) {
+				c.logger.Error().Err(err).Msg("Skip invalid mid frame")
+				continue
+			}
 			if err != nil {
 				c.logger.Error().Err(err).Msg("Failed to read from connection")
-				return
+				return err
 			}
 			c.logger.Info().Bytes("data", data).Msg("Receive mid message")
-			if len(data) < 20 {
-				c.logger.Error().Msg("Invalid mid header lenght")
-				return
+			if c.sequence && !c.linkLevel.Load() && string(data[4:8]) == "0002" && linkSupported(data) {
+				c.startLink()
+			}
+			if c.linkLevel.Load() && !c.link(data) {
+				continue
+			}
+			frame, ok, err := asm.Add(data)
+			if err != nil {
+				c.logger.Error().Err(err).Msg("Failed to join linked mid message")
+				continue
+			}
+			if !ok {
+				continue
+			}
+			data = append(frame, '\x00')
+			v, _ := c.chans.Load(string(data[4:8]))
+			if s, ok := v.(*subscription); ok {
+				c.enqueue(s, data, dropped)
+				continue
 			}
-			go func(key string) {
-				switch key {
-				case
-					jobInfoSub,
-					vinSub,
-					tighteningSub,
-					multiSpindelSub,
-					powerMACSTighteningSub,
-					powerMACSTighteningBoltSub:
-					v, _ := c.chans.Load(key)
-					p, ok := v.(*Publisher)
-					if ok {
-						p.Write(data)
-					}
-				default:
-					c.feedback.Write(data)
-				}
-			}(string(data[4:8]))
+			c.feedback(data)
 		}
 	}
 }
 
-func (c *Client) execCMD(mid MID, f func(mid MID) error) error {
+// keepAliveLoop sends MID 9999 when the link has been idle for the keep-alive
+// interval and drops the connection when the echo does not come back in time.
+func (c *Client) keepAliveLoop() {
+	tick := c.keepAlive / 4
+	if tick <= 0 {
+		tick = c.keepAlive
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.closed:
+			return
+		case <-ticker.C:
+		}
+		if !c.connected.Load() || time.Since(time.Unix(0, c.lastSent.Load())) < c.keepAlive {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(own(context.Background()), c.keepAliveTimeout)
+		err := c.KeepAliveMessageContext(ctx)
+		cancel()
+		if err == nil || errors.Is(err, ErrClientClosed) || errors.Is(err, ErrConnectionLost) {
+			continue
+		}
+		c.logger.Error().Err(err).Msg("Keep alive failed")
+		c.drop(fmt.Errorf("%w: %v", ErrKeepAlive, err))
+	}
+}
+
+// drop closes the current connection on behalf of the client itself, e.g. on
+// a dead link. The read loop then reports cause instead of the read error.
+func (c *Client) drop(cause error) {
+	c.mu.Lock()
+	c.cause = cause
+	c.mu.Unlock()
+	c.disconnect()
+}
+
+// abort drops the connection after a failed write, unless it is already
+// gone and the write failed because of that.
+func (c *Client) abort(err error) {
+	if c.connected.Load() {
+		c.logger.Error().Err(err).Msg("Failed to write to connection")
+		c.drop(err)
+	}
+}
+
+func (c *Client) takeCause(err error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cause != nil {
+		err, c.cause = c.cause, nil
+	}
+	return err
+}
+
+func (c *Client) notify(state ConnectionState, err error) {
+	if c.stateHandler != nil {
+		c.stateHandler(ConnectionEvent{State: state, Err: err})
+	}
+}
+
+// disconnect closes the current connection so that no command can be written
+// to it after the read loop has given up on it. It does not wait for wmu:
+// closing unblocks a write stuck on a controller that stopped reading. It
+// also closes dropped, unblocking a read loop stuck on a subscriber.
+func (c *Client) disconnect() {
+	c.connected.Store(false)
+	c.mu.Lock()
+	conn := c.conn
+	select {
+	case <-c.dropped:
+	default:
+		close(c.dropped)
+	}
+	c.mu.Unlock()
+	conn.Close()
+}
+
+// redial connects again with exponential backoff. Subscription publishers
+// stay open meanwhile so consumers keep their channels through the outage.
+func (c *Client) redial() bool {
+	backoff := c.backoff
+	for {
+		c.logger.Warn().Dur("backoff", backoff).Msg("Reconnect to controller")
+		select {
+		case <-c.done:
+			return false
+		case <-time.After(backoff):
+		}
+		conn, err := dial(c.addr)
+		if err != nil {
+			c.logger.Error().Err(err).Msg("Failed to reconnect to controller")
+			backoff *= 2
+			if c.maxBackoff > 0 && backoff > c.maxBackoff {
+				backoff = c.maxBackoff
+			}
+			continue
+		}
+		c.wmu.Lock()
+		c.mu.Lock()
+		c.conn = conn
+		c.dropped = make(chan struct{})
+		c.mu.Unlock()
+		c.wmu.Unlock()
+		return true
+	}
+}
+
+// restore brings the session back to where it was before the connection was
+// lost: it starts the application communication again and resends every
+// subscription that was active. Commands of the caller are held meanwhile.
+// If the session can not be restored the connection is dropped, so it is
+// reported and dialed again. A subscription the controller refuses is
+// closed.
+func (c *Client) restore(held chan struct{}) {
+	defer c.release(held)
+	ctx := own(context.Background())
+	if c.started.Load() {
+		if err := c.ApplicationCommunicationStartContext(ctx); err != nil {
+			c.logger.Error().Err(err).Msg("Failed to restore communication")
+			c.drop(fmt.Errorf("%w: %v", ErrRestore, err))
+			return
+		}
+	}
+	c.subs.Range(func(key, value any) bool {
+		s, ok := value.(*subscription)
+		if !ok {
+			return true
+		}
+		mid := s.mid
+		err := c.execCMD(ctx, mid, standartHandler)
+		if err == nil {
+			return true
+		}
+		c.logger.Error().Err(err).Int("mid", mid.Header.MID).Msg("Failed to restore subscription")
+		var refused *MID0004REV001
+		if errors.As(err, &refused) {
+			c.forget(s)
+			s.close()
+			return true
+		}
+		c.drop(fmt.Errorf("%w: %v", ErrRestore, err))
+		return false
+	})
+}
+
+// ownKey marks the context of a command the client sends on its own, which
+// must not wait for the session to be restored.
+type ownKey struct{}
+
+func own(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ownKey{}, true)
+}
+
+// hold makes commands of the caller wait until the returned channel is
+// released.
+func (c *Client) hold() chan struct{} {
+	held := make(chan struct{})
+	c.mu.Lock()
+	c.held = held
+	c.mu.Unlock()
+	return held
+}
+
+func (c *Client) release(held chan struct{}) {
+	c.mu.Lock()
+	if c.held == held {
+		c.held = nil
+	}
+	c.mu.Unlock()
+	close(held)
+}
+
+// await waits until the session is restored, unless ctx belongs to a
+// command of the client itself.
+func (c *Client) await(ctx context.Context) error {
+	if ctx.Value(ownKey{}) != nil {
+		return nil
+	}
+	c.mu.Lock()
+	held := c.held
+	c.mu.Unlock()
+	if held == nil {
+		return nil
+	}
+	select {
+	case <-held:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.closed:
+		return ErrClientClosed
+	}
+}
+
+// waiter is a command waiting for its reply: the MIDs answering it, or a
+// MID 0004/0005 naming the request MID.
+type waiter struct {
+	request int
+	replies []int
+	ch      chan []byte
+}
+
+func (w *waiter) match(mid int, data []byte) bool {
+	if mid == 4 || mid == 5 {
+		if len(data) < 24 {
+			return false
+		}
+		request, err := strconv.Atoi(string(data[20:24]))
+		return err == nil && request == w.request
+	}
+	for _, reply := range w.replies {
+		if mid == reply {
+			return true
+		}
+	}
+	return false
+}
+
+// feedback hands a reply to the command waiting for it. Anything else,
+// including replies arriving after the command has given up, goes to the
+// unsolicited message handler so it can not be taken as the answer to the
+// next command.
+func (c *Client) feedback(data []byte) {
+	mid, err := strconv.Atoi(string(data[4:8]))
+	if err != nil {
+		c.logger.Error().Err(err).Bytes("data", data).Msg("Invalid mid number")
+		return
+	}
+	c.mu.Lock()
+	w := c.waiter
+	if w != nil && w.match(mid, data) {
+		c.waiter = nil
+	} else {
+		w = nil
+	}
+	c.mu.Unlock()
+	if w == nil {
+		c.unsolicited(data)
+		return
+	}
+	w.ch <- data
+}
+
+func (c *Client) unsolicited(data []byte) {
+	if c.unsolicitedHandler == nil {
+		c.logger.Warn().Bytes("data", data).Msg("Drop unsolicited mid message")
+		return
+	}
+	mid := MID{}
+	if err := UnmarshalMID(bytes.TrimSuffix(data, []byte{'\x00'}), &mid); err != nil {
+		c.logger.Error().Err(err).Bytes("data", data).Msg("Invalid unsolicited mid message")
+		return
+	}
+	c.unsolicitedHandler(mid)
+}
+
+// fail releases the command waiting for a reply on a lost connection.
+func (c *Client) fail() {
+	c.mu.Lock()
+	w := c.waiter
+	c.waiter = nil
+	c.mu.Unlock()
+	if w != nil {
+		close(w.ch)
+	}
+}
+
+func (c *Client) wait(w *waiter) {
+	c.mu.Lock()
+	c.waiter = w
+	c.mu.Unlock()
+}
+
+// execCMD sends mid and passes the reply to f. The reply is either one of
+// replies or MID 0004/0005 for mid; without replies only MID 0004/0005 are
+// expected.
+func (c *Client) execCMD(ctx context.Context, mid MID, f func(mid MID) error, replies ...int) error {
 	if f == nil {
 		return fmt.Errorf("nil feedback handler func")
 	}
-	payload, err := MarshalMID(mid)
+	payload, err := MarshalMID(mid, c.codec...)
 	if err != nil {
 		return err
 	}
-	raw, err := c.do(payload)
+	raw, err := c.do(ctx, payload, mid.Header.MID, replies)
 	if err != nil {
 		return err
 	}
@@ -400,32 +886,254 @@ func (c *Client) execCMD(mid MID, f func(mid MID) error) error {
 	return f(mid)
 }
 
-func (c *Client) do(payload []byte) ([]byte, error) {
-	c.semaphore <- struct{}{}
+func (c *Client) do(ctx context.Context, payload []byte, request int, replies []int) ([]byte, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	if err := c.await(ctx); err != nil {
+		return nil, err
+	}
+	select {
+	case c.semaphore <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.closed:
+		return nil, ErrClientClosed
+	}
 	defer func() { <-c.semaphore }()
-	c.logger.Info().Bytes("data", payload).Msg("Send mid message")
-	if _, err := c.conn.Write(append(payload, '\x00')); err != nil {
+	reply := make(chan []byte, 1)
+	c.wait(&waiter{request: request, replies: replies, ch: reply})
+	defer c.wait(nil)
+	if err := c.write(ctx, payload); err != nil {
 		return nil, err
 	}
-	data, ok := <-c.feedback.Read()
-	if !ok {
-		return nil, fmt.Errorf("error feedback")
+	select {
+	case data, ok := <-reply:
+		if !ok {
+			return nil, ErrConnectionLost
+		}
+		return data, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.closed:
+		return nil, fmt.Errorf("error feedback: %w", ErrClientClosed)
+	}
+}
+
+func (c *Client) subscribe(ctx context.Context, mid, cancel MID, opts []SubscribeOption, keys ...string) (<-chan []byte, error) {
+	s := &subscription{
+		mid:       mid,
+		cancel:    cancel,
+		keys:      keys,
+		publisher: NewPublisher(),
+		size:      defaultQueueSize,
+		overflow:  OverflowFail,
+		done:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
-	return data, nil
+	s.queue = make(chan []byte, s.size)
+	go c.run(s)
+	for _, key := range keys {
+		c.chans.Store(key, s)
+	}
+	if err := c.execCMD(ctx, mid, standartHandler); err != nil {
+		for _, key := range keys {
+			c.chans.Delete(key)
+		}
+		s.close()
+		return nil, err
+	}
+	c.subs.Store(keys[0], s)
+	return s.publisher.Read(), nil
+}
+
+func (c *Client) unsubscribe(ctx context.Context, mid MID, keys ...string) error {
+	if err := c.execCMD(ctx, mid, standartHandler); err != nil {
+		return err
+	}
+	c.subs.Delete(keys[0])
+	for _, key := range keys {
+		if v, ok := c.chans.LoadAndDelete(key); ok {
+			if s, ok := v.(*subscription); ok {
+				s.close()
+			}
+		}
+	}
+	return nil
+}
+
+// enqueue queues a pushed frame for the subscription's delivery goroutine,
+// applying its overflow policy when the queue is full. A blocked reader
+// gives up on the frame once the connection is dropped, so a dead link is
+// still noticed.
+func (c *Client) enqueue(s *subscription, data []byte, dropped <-chan struct{}) {
+	switch s.overflow {
+	case OverflowDropOldest:
+		for {
+			select {
+			case s.queue <- data:
+				return
+			case <-s.done:
+				return
+			default:
+			}
+			select {
+			case old := <-s.queue:
+				c.logger.Warn().Bytes("data", old).Msg("Drop oldest queued mid message")
+			default:
+			}
+		}
+	case OverflowFail:
+		select {
+		case s.queue <- data:
+		case <-s.done:
+		default:
+			c.logger.Error().Int("mid", s.mid.Header.MID).Msg("Subscription queue overflow, closing subscription")
+			c.drain(s)
+		}
+	default:
+		select {
+		case s.queue <- data:
+		case <-s.done:
+		case <-c.done:
+		case <-dropped:
+		}
+	}
+}
+
+// drain closes the channel of a failed subscription and ends it on the
+// controller. Closing it first makes enqueue skip the frames still pushed
+// until the unsubscribe is answered.
+func (c *Client) drain(s *subscription) {
+	s.close()
+	go func() {
+		if err := c.unsubscribe(context.Background(), s.cancel, s.keys...); err != nil {
+			c.logger.Error().Err(err).Int("mid", s.cancel.Header.MID).Msg("Failed to unsubscribe failed subscription")
+			c.forget(s)
+		}
+	}()
 }
 
-func (c *Client) acknowledge(mid MID) error {
-	payload, err := MarshalMID(mid)
+// forget removes a subscription the controller could not be told to end.
+func (c *Client) forget(s *subscription) {
+	for _, m := range []*sync.Map{&c.chans, &c.subs} {
+		m.Range(func(key, value any) bool {
+			if value == s {
+				m.Delete(key)
+			}
+			return true
+		})
+	}
+}
+
+// run delivers queued frames of a subscription one by one, in the order
+// they were received.
+func (c *Client) run(s *subscription) {
+	for {
+		select {
+		case <-s.done:
+			return
+		case data := <-s.queue:
+			c.deliver(s, data)
+		}
+	}
+}
+
+// deliver hands a pushed frame to the subscriber and acknowledges it as the
+// subscription's ack policy requires.
+func (c *Client) deliver(s *subscription, data []byte) {
+	if s.policy == AckOnReceive {
+		c.acknowledgeSubscription(s)
+	}
+	if s.publisher.Write(data) && s.policy == AckOnConfirm && !s.decoded {
+		c.acknowledgeSubscription(s)
+	}
+}
+
+func (c *Client) acknowledgeSubscription(s *subscription) {
+	if err := c.ackSubscription(context.Background(), s); err != nil {
+		c.logger.Error().Err(err).Int("mid", s.mid.Header.MID).Msg("Failed to acknowledge subscription")
+	}
+}
+
+func (c *Client) ackSubscription(ctx context.Context, s *subscription) error {
+	if c.linkLevel.Load() {
+		return nil
+	}
+	switch s.mid.Header.MID {
+	case 34:
+		return c.JobInfoAcknowledgeContext(ctx)
+	case 51:
+		return c.VehicleIDNumberAcknowledgeContext(ctx)
+	case 60:
+		return c.LastTighteningResultDataAcknowledgeContext(ctx)
+	case 100:
+		return c.MultiSpindleResultAcknowledgeContext(ctx)
+	case 105:
+		return c.LastPowerMACSTighteningResultDataAcknowledgeContext(ctx, s.boltData)
+	}
+	return fmt.Errorf("no acknowledge for subscription mid %d", s.mid.Header.MID)
+}
+
+// confirm returns the func acknowledging a decoded message of the
+// subscription stored under key, if its policy waits for confirmation.
+func (c *Client) confirm(key string) func(ctx context.Context) error {
+	v, _ := c.chans.Load(key)
+	s, ok := v.(*subscription)
+	if !ok || s.policy != AckOnConfirm {
+		return nil
+	}
+	return func(ctx context.Context) error {
+		return c.ackSubscription(ctx, s)
+	}
+}
+
+func (c *Client) acknowledge(ctx context.Context, mid MID) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	payload, err := MarshalMID(mid, c.codec...)
 	if err != nil {
 		return err
 	}
+	return c.write(ctx, payload)
+}
+
+// write sends a single frame. Cancelling the context does not interrupt a
+// write in progress, so the connection stays in sync; only the context
+// deadline bounds the write. A write that fails may have left part of the
+// frame on the wire, so the connection is dropped to start over cleanly.
+func (c *Client) write(ctx context.Context, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !c.connected.Load() {
+		return ErrConnectionLost
+	}
+	deadline, _ := ctx.Deadline()
+	if err := c.conn.SetWriteDeadline(deadline); err != nil {
+		c.abort(err)
+		return err
+	}
+	c.stamp(payload)
 	c.logger.Info().Bytes("data", payload).Msg("Send mid message")
 	if _, err := c.conn.Write(append(payload, '\x00')); err != nil {
+		c.abort(err)
 		return err
 	}
+	c.lastSent.Store(time.Now().UnixNano())
 	return nil
 }
 
+func (c *Client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || c.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.timeout)
+}
+
 func standartHandler(mid MID) error {
 	if mid.Header.MID == 4 {
 		return midErr(mid)
@@ -0,0 +1,601 @@
+package mid_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/rlz-buro/mid"
+)
+
+// controller is a minimal Open Protocol controller answering each received
+// frame with whatever the handler returns.
+type controller struct {
+	ln      net.Listener
+	handler func(frame []byte) [][]byte
+	mu      sync.Mutex
+	conns   []net.Conn
+}
+
+func newController(handler func(frame []byte) [][]byte) (*controller, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	ctrl := &controller{ln: ln, handler: handler}
+	go ctrl.serve()
+	return ctrl, nil
+}
+
+func (ctrl *controller) serve() {
+	for {
+		conn, err := ctrl.ln.Accept()
+		if err != nil {
+			return
+		}
+		ctrl.mu.Lock()
+		ctrl.conns = append(ctrl.conns, conn)
+		ctrl.mu.Unlock()
+		go func(conn net.Conn) {
+			defer conn.Close()
+			r := bufio.NewReader(conn)
+			for {
+				frame, err := r.ReadBytes('\x00')
+				if err != nil {
+					return
+				}
+				for _, reply := range ctrl.handler(frame[:len(frame)-1]) {
+					ctrl.mu.Lock()
+					_, err := conn.Write(append(reply, '\x00'))
+					ctrl.mu.Unlock()
+					if err != nil {
+						return
+					}
+				}
+			}
+		}(conn)
+	}
+}
+
+func (ctrl *controller) addr() (string, string) {
+	host, port, _ := net.SplitHostPort(ctrl.ln.Addr().String())
+	return host, port
+}
+
+// push writes an unsolicited frame to every connected client.
+func (ctrl *controller) push(frame []byte) {
+	ctrl.mu.Lock()
+	defer ctrl.mu.Unlock()
+	for _, conn := range ctrl.conns {
+		conn.Write(append(frame, '\x00'))
+	}
+}
+
+// drop closes every client connection while still accepting new ones.
+func (ctrl *controller) drop() {
+	ctrl.mu.Lock()
+	defer ctrl.mu.Unlock()
+	for _, conn := range ctrl.conns {
+		conn.Close()
+	}
+	ctrl.conns = nil
+}
+
+func (ctrl *controller) close() {
+	ctrl.ln.Close()
+}
+
+type ClientTestSuite struct {
+	suite.Suite
+}
+
+func TestClientTestSuite(t *testing.T) {
+	suite.Run(t, new(ClientTestSuite))
+}
+
+func (suite *ClientTestSuite) TestContextDeadline() {
+	var silent atomic.Bool
+	silent.Store(true)
+	ctrl, err := newController(func(frame []byte) [][]byte {
+		if silent.Load() {
+			return nil
+		}
+		return [][]byte{[]byte("00200002001000000000")}
+	})
+	suite.Require().NoError(err)
+	defer ctrl.close()
+	host, port := ctrl.addr()
+	cln, err := mid.NewClient(host, port, zerolog.Nop())
+	suite.Require().NoError(err)
+	defer cln.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = cln.ApplicationCommunicationStartContext(ctx)
+	suite.ErrorIs(err, context.DeadlineExceeded)
+
+	silent.Store(false)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	suite.NoError(cln.ApplicationCommunicationStartContext(ctx))
+}
+
+func (suite *ClientTestSuite) TestRequestTimeout() {
+	ctrl, err := newController(func(frame []byte) [][]byte { return nil })
+	suite.Require().NoError(err)
+	defer ctrl.close()
+	host, port := ctrl.addr()
+	cln, err := mid.NewClient(host, port, zerolog.Nop(), mid.WithRequestTimeout(50*time.Millisecond))
+	suite.Require().NoError(err)
+	defer cln.Close()
+
+	suite.ErrorIs(cln.KeepAliveMessage(), context.DeadlineExceeded)
+}
+
+func (suite *ClientTestSuite) TestReconnect() {
+	var subscriptions atomic.Int32
+	ctrl, err := newController(func(frame []byte) [][]byte {
+		switch string(frame[4:8]) {
+		case "0001":
+			return [][]byte{[]byte("00200002001000000000")}
+		case "0060":
+			subscriptions.Add(1)
+			return [][]byte{[]byte("002400050010000000000060")}
+		}
+		return nil
+	})
+	suite.Require().NoError(err)
+	defer ctrl.close()
+	host, port := ctrl.addr()
+	cln, err := mid.NewClient(host, port, zerolog.Nop(), mid.WithReconnect(10*time.Millisecond, 50*time.Millisecond))
+	suite.Require().NoError(err)
+	defer cln.Close()
+
+	suite.Require().NoError(cln.ApplicationCommunicationStart())
+	ch, err := cln.LastTighteningResultDataSubscribe()
+	suite.Require().NoError(err)
+
+	ctrl.drop()
+	suite.Eventually(func() bool { return subscriptions.Load() == 2 }, time.Second, 10*time.Millisecond)
+
+	push := []byte("00200061001000000000")
+	ctrl.push(push)
+	select {
+	case data, ok := <-ch:
+		suite.True(ok)
+		suite.Equal(append(push, '\x00'), data)
+	case <-time.After(time.Second):
+		suite.Fail("no push received after reconnect")
+	}
+}
+
+func (suite *ClientTestSuite) TestRestoreFailure() {
+	var starts, subscriptions atomic.Int32
+	ctrl, err := newController(func(frame []byte) [][]byte {
+		switch string(frame[4:8]) {
+		case "0001":
+			if starts.Add(1) == 2 {
+				return [][]byte{[]byte("00260004001000000000000196")}
+			}
+			return [][]byte{[]byte("00200002001000000000")}
+		case "0060":
+			subscriptions.Add(1)
+			return [][]byte{[]byte("002400050010000000000060")}
+		}
+		return nil
+	})
+	suite.Require().NoError(err)
+	defer ctrl.close()
+	host, port := ctrl.addr()
+	events := make(chan mid.ConnectionEvent, 8)
+	cln, err := mid.NewClient(host, port, zerolog.Nop(),
+		mid.WithReconnect(10*time.Millisecond, 50*time.Millisecond),
+		mid.WithConnectionStateHandler(func(e mid.ConnectionEvent) { events <- e }),
+	)
+	suite.Require().NoError(err)
+	defer cln.Close()
+
+	suite.Require().NoError(cln.ApplicationCommunicationStart())
+	_, err = cln.LastTighteningResultDataSubscribe()
+	suite.Require().NoError(err)
+
+	ctrl.drop()
+	var states []mid.ConnectionState
+	var errs []error
+	for len(states) < 4 {
+		select {
+		case e := <-events:
+			states = append(states, e.State)
+			errs = append(errs, e.Err)
+		case <-time.After(time.Second):
+			suite.FailNow("session not restored", "states %v", states)
+		}
+	}
+	suite.Equal([]mid.ConnectionState{mid.StateDisconnected, mid.StateConnected, mid.StateDisconnected, mid.StateConnected}, states)
+	suite.ErrorIs(errs[2], mid.ErrRestore)
+	suite.Eventually(func() bool { return subscriptions.Load() == 2 }, time.Second, 10*time.Millisecond)
+}
+
+func (suite *ClientTestSuite) TestRestoreRefusedSubscription() {
+	var subscriptions atomic.Int32
+	ctrl, err := newController(func(frame []byte) [][]byte {
+		if string(frame[4:8]) != "0060" {
+			return nil
+		}
+		if subscriptions.Add(1) == 2 {
+			return [][]byte{[]byte("00260004001000000000006009")}
+		}
+		return [][]byte{[]byte("002400050010000000000060")}
+	})
+	suite.Require().NoError(err)
+	defer ctrl.close()
+	host, port := ctrl.addr()
+	cln, err := mid.NewClient(host, port, zerolog.Nop(), mid.WithReconnect(10*time.Millisecond, 50*time.Millisecond))
+	suite.Require().NoError(err)
+	defer cln.Close()
+
+	ch, err := cln.LastTighteningResultDataSubscribe()
+	suite.Require().NoError(err)
+	ctrl.drop()
+	select {
+	case _, ok := <-ch:
+		suite.False(ok)
+	case <-time.After(time.Second):
+		suite.Fail("refused subscription not closed")
+	}
+}
+
+func (suite *ClientTestSuite) TestKeepAliveDeadLink() {
+	ctrl, err := newController(func(frame []byte) [][]byte { return nil })
+	suite.Require().NoError(err)
+	defer ctrl.close()
+	host, port := ctrl.addr()
+	events := make(chan mid.ConnectionEvent, 8)
+	cln, err := mid.NewClient(host, port, zerolog.Nop(),
+		mid.WithKeepAlive(20*time.Millisecond, 0),
+		mid.WithConnectionStateHandler(func(e mid.ConnectionEvent) { events <- e }),
+	)
+	suite.Require().NoError(err)
+	defer cln.Close()
+
+	select {
+	case e := <-events:
+		suite.Equal(mid.StateDisconnected, e.State)
+		suite.ErrorIs(e.Err, mid.ErrKeepAlive)
+	case <-time.After(time.Second):
+		suite.Fail("dead link not reported")
+	}
+	suite.Error(cln.KeepAliveMessage())
+}
+
+func (suite *ClientTestSuite) TestAckPolicy() {
+	acks := make(chan string, 8)
+	ctrl, err := newController(func(frame []byte) [][]byte {
+		switch key := string(frame[4:8]); key {
+		case "0034", "0060":
+			return [][]byte{[]byte("00240005001000000000" + key)}
+		case "0036", "0062":
+			acks <- key
+		}
+		return nil
+	})
+	suite.Require().NoError(err)
+	defer ctrl.close()
+	host, port := ctrl.addr()
+	cln, err := mid.NewClient(host, port, zerolog.Nop())
+	suite.Require().NoError(err)
+	defer cln.Close()
+
+	_, err = cln.JobInfoSubscribe(mid.WithAck(mid.AckOnReceive))
+	suite.Require().NoError(err)
+	ctrl.push([]byte("00200035001000000000"))
+	select {
+	case key := <-acks:
+		suite.Equal("0036", key)
+	case <-time.After(time.Second):
+		suite.Fail("job info not acknowledged on receive")
+	}
+
+	ch, err := cln.LastTighteningResultDataSubscribeDecoded(mid.WithAck(mid.AckOnConfirm))
+	suite.Require().NoError(err)
+	ctrl.push([]byte("00200061001000000000"))
+	msg := <-ch
+	suite.Error(msg.Err)
+	select {
+	case <-acks:
+		suite.Fail("tightening result acknowledged before confirmation")
+	case <-time.After(50 * time.Millisecond):
+	}
+	suite.NoError(msg.Ack())
+	select {
+	case key := <-acks:
+		suite.Equal("0062", key)
+	case <-time.After(time.Second):
+		suite.Fail("tightening result not acknowledged on confirm")
+	}
+}
+
+// mid0002 is a MID 0002 revision 6 reply with sequence number 1, telling
+// whether sequence numbers are supported.
+func mid0002(supported bool) []byte {
+	support := "0"
+	if supported {
+		support = "1"
+	}
+	return []byte("01790002006000000100" +
+		"010001" + "0201" + "03" + fmt.Sprintf("%-25s", "ctrl") + "04ACT" +
+		"05" + fmt.Sprintf("%-19s", "2.0") + "06" + fmt.Sprintf("%-19s", "1.0") + "07" + fmt.Sprintf("%-19s", "1.0") +
+		"08" + fmt.Sprintf("%-24s", "rbu") + "090000000001" + "10001" + "11001" +
+		"12" + support + "130")
+}
+
+func (suite *ClientTestSuite) TestSequenceNumbers() {
+	frames := make(chan string, 16)
+	var keepAlives atomic.Int32
+	ctrl, err := newController(func(frame []byte) [][]byte {
+		frames <- string(frame)
+		switch string(frame[4:8]) {
+		case "0001":
+			return [][]byte{mid0002(true)}
+		case "9999":
+			if keepAlives.Add(1) == 1 {
+				return [][]byte{[]byte("00260998001000000200999903")}
+			}
+			return [][]byte{[]byte("00209999001000000200")}
+		}
+		return nil
+	})
+	suite.Require().NoError(err)
+	defer ctrl.close()
+	host, port := ctrl.addr()
+	cln, err := mid.NewClient(host, port, zerolog.Nop(), mid.WithSequenceNumbers(), mid.WithRequestTimeout(time.Second))
+	suite.Require().NoError(err)
+	defer cln.Close()
+
+	suite.Require().NoError(cln.ApplicationCommunicationStart())
+	suite.Equal("00200001006000000100", <-frames)
+	suite.Equal("002409970010000001000002", <-frames)
+
+	suite.Require().NoError(cln.KeepAliveMessage())
+	suite.Equal("00209999001000000200", <-frames)
+	suite.Equal("00209999001000000200", <-frames)
+	suite.Equal("002409970010000002009999", <-frames)
+
+	ctrl.push([]byte("00209999001000000200"))
+	suite.Equal("002409970010000002009999", <-frames)
+	ctrl.push([]byte("00209999001000000500"))
+	suite.Equal("00260998001000000500999903", <-frames)
+}
+
+func (suite *ClientTestSuite) TestSequenceNumbersUnsupported() {
+	for name, reply := range map[string][]byte{
+		"not supported":        mid0002(false),
+		"header copied":        []byte("00200002001000000100"),
+		"revision unsupported": []byte("00260004001000000000000197"),
+	} {
+		frames := make(chan string, 16)
+		ctrl, err := newController(func(frame []byte) [][]byte {
+			frames <- string(frame)
+			switch string(frame[4:8]) {
+			case "0001":
+				if frame[10] == '6' {
+					return [][]byte{reply}
+				}
+				return [][]byte{[]byte("00200002001000000000")}
+			case "9999":
+				return [][]byte{[]byte("00209999001000000000")}
+			}
+			return nil
+		})
+		suite.Require().NoError(err)
+		host, port := ctrl.addr()
+		cln, err := mid.NewClient(host, port, zerolog.Nop(), mid.WithSequenceNumbers(), mid.WithRequestTimeout(time.Second))
+		suite.Require().NoError(err)
+
+		suite.Require().NoError(cln.ApplicationCommunicationStart(), name)
+		suite.Equal("00200001006000000100", <-frames, name)
+		if name == "revision unsupported" {
+			suite.Equal("00200001001000000000", <-frames, name)
+		}
+		suite.Require().NoError(cln.KeepAliveMessage(), name)
+		suite.Equal("00209999001000000000", <-frames, name)
+		cln.Close()
+		ctrl.close()
+	}
+}
+
+func (suite *ClientTestSuite) TestUnsolicited() {
+	received := make(chan struct{})
+	ctrl, err := newController(func(frame []byte) [][]byte {
+		close(received)
+		return nil
+	})
+	suite.Require().NoError(err)
+	defer ctrl.close()
+	host, port := ctrl.addr()
+	unsolicited := make(chan mid.MID, 1)
+	cln, err := mid.NewClient(host, port, zerolog.Nop(),
+		mid.WithRequestTimeout(time.Second),
+		mid.WithUnsolicitedHandler(func(m mid.MID) { unsolicited <- m }),
+	)
+	suite.Require().NoError(err)
+	defer cln.Close()
+
+	errs := make(chan error)
+	go func() { errs <- cln.KeepAliveMessage() }()
+	<-received
+	ctrl.push([]byte("00200071001000000000"))
+	select {
+	case m := <-unsolicited:
+		suite.Equal(71, m.Header.MID)
+	case <-time.After(time.Second):
+		suite.Fail("unsolicited message not handled")
+	}
+	ctrl.push([]byte("00209999001000000000"))
+	suite.NoError(<-errs)
+}
+
+func (suite *ClientTestSuite) TestSubscriptionQueue() {
+	unsubscribed := make(chan struct{})
+	ctrl, err := newController(func(frame []byte) [][]byte {
+		switch key := string(frame[4:8]); key {
+		case "0051", "0060":
+			return [][]byte{[]byte("00240005001000000000" + key)}
+		case "0054":
+			close(unsubscribed)
+			return [][]byte{[]byte("00240005001000000000" + key)}
+		}
+		return nil
+	})
+	suite.Require().NoError(err)
+	defer ctrl.close()
+	host, port := ctrl.addr()
+	cln, err := mid.NewClient(host, port, zerolog.Nop())
+	suite.Require().NoError(err)
+	defer cln.Close()
+
+	results, err := cln.LastTighteningResultDataSubscribe()
+	suite.Require().NoError(err)
+	vins, err := cln.VehicleIDNumberSubscribe(mid.WithQueue(1, mid.OverflowFail))
+	suite.Require().NoError(err)
+	for i := 1; i <= 5; i++ {
+		ctrl.push([]byte(fmt.Sprintf("00240061001000000000%04d", i)))
+		time.Sleep(5 * time.Millisecond)
+		ctrl.push([]byte(fmt.Sprintf("00240052001000000000%04d", i)))
+		time.Sleep(5 * time.Millisecond)
+	}
+	for i := 1; i <= 5; i++ {
+		suite.Equal(fmt.Sprintf("00240061001000000000%04d\x00", i), string(<-results))
+	}
+	suite.Eventually(func() bool {
+		for {
+			select {
+			case _, ok := <-vins:
+				if !ok {
+					return true
+				}
+			default:
+				return false
+			}
+		}
+	}, time.Second, 10*time.Millisecond)
+	select {
+	case <-unsubscribed:
+	case <-time.After(time.Second):
+		suite.Fail("failed subscription not unsubscribed")
+	}
+}
+
+func (suite *ClientTestSuite) TestBlockedSubscriberDeadLink() {
+	ctrl, err := newController(func(frame []byte) [][]byte {
+		switch key := string(frame[4:8]); key {
+		case "0060":
+			return [][]byte{[]byte("00240005001000000000" + key)}
+		case "9999":
+			return [][]byte{[]byte("00209999001000000000")}
+		}
+		return nil
+	})
+	suite.Require().NoError(err)
+	defer ctrl.close()
+	host, port := ctrl.addr()
+	events := make(chan mid.ConnectionEvent, 8)
+	cln, err := mid.NewClient(host, port, zerolog.Nop(),
+		mid.WithKeepAlive(100*time.Millisecond, 50*time.Millisecond),
+		mid.WithConnectionStateHandler(func(e mid.ConnectionEvent) { events <- e }),
+	)
+	suite.Require().NoError(err)
+	defer cln.Close()
+
+	_, err = cln.LastTighteningResultDataSubscribe(mid.WithQueue(1, mid.OverflowBlock))
+	suite.Require().NoError(err)
+	for i := 0; i < 30; i++ {
+		ctrl.push([]byte(fmt.Sprintf("00240061001000000000%04d", i)))
+	}
+	select {
+	case e := <-events:
+		suite.Equal(mid.StateDisconnected, e.State)
+		suite.ErrorIs(e.Err, mid.ErrKeepAlive)
+	case <-time.After(2 * time.Second):
+		suite.Fail("dead link behind a blocked subscriber not reported")
+	}
+}
+
+func (suite *ClientTestSuite) TestWriteTimeoutDrops() {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	suite.Require().NoError(err)
+	defer ln.Close()
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		// Accept but never read, so the client's writes eventually block.
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		conn.(*net.TCPConn).SetReadBuffer(1024)
+		<-stop
+		conn.Close()
+	}()
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	events := make(chan mid.ConnectionEvent, 8)
+	cln, err := mid.NewClient(host, port, zerolog.Nop(),
+		mid.WithConnectionStateHandler(func(e mid.ConnectionEvent) { events <- e }),
+	)
+	suite.Require().NoError(err)
+	defer cln.Close()
+
+	// Write from several goroutines until one runs past its deadline, either
+	// taking the lock too late or blocking on full socket buffers.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+				err := cln.JobInfoAcknowledgeContext(ctx)
+				cancel()
+				if errors.Is(err, mid.ErrConnectionLost) {
+					return
+				}
+			}
+		}()
+	}
+	select {
+	case e := <-events:
+		suite.Equal(mid.StateDisconnected, e.State)
+		suite.ErrorIs(e.Err, os.ErrDeadlineExceeded)
+	case <-time.After(30 * time.Second):
+		suite.FailNow("timed out write did not drop the connection")
+	}
+	wg.Wait()
+	suite.ErrorIs(cln.JobInfoAcknowledge(), mid.ErrConnectionLost)
+}
+
+func (suite *ClientTestSuite) TestBlankDefaults() {
+	frames := make(chan string, 1)
+	ctrl, err := newController(func(frame []byte) [][]byte {
+		frames <- string(frame)
+		return [][]byte{[]byte("00200002   0        ")}
+	})
+	suite.Require().NoError(err)
+	defer ctrl.close()
+	host, port := ctrl.addr()
+	cln, err := mid.NewClient(host, port, zerolog.Nop(), mid.WithCodec(mid.WithBlankDefaults()))
+	suite.Require().NoError(err)
+	defer cln.Close()
+
+	suite.NoError(cln.ApplicationCommunicationStart())
+	suite.Equal("00200001   0        ", <-frames)
+}
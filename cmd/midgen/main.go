@@ -0,0 +1,631 @@
+// Command midgen generates MID types from a declarative spec.
+//
+// The spec is a JSON file listing enums and, for each MID revision, its
+// fields in the order they are sent. Positions are derived from the field
+// widths and parameter IDs, so they never have to be counted by hand. For
+// every MID midgen writes mid<MID>.go holding all of its revisions and, in
+// addition, registry_gen.go registering the types with Decode and
+// mid_gen_test.go with a round trip test of each type and revision.
+//
+//	go run ./cmd/midgen -spec mids.json -out .
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	header    = "// Code generated by midgen. DO NOT EDIT.\n\n"
+	dataStart = 21
+)
+
+// Spec is the declarative description of the generated types.
+type Spec struct {
+	Package  string    `json:"package"`
+	Enums    []Enum    `json:"enums"`
+	Messages []Message `json:"messages"`
+}
+
+// Enum is an integer type with named values.
+type Enum struct {
+	Name   string      `json:"name"`
+	Doc    []string    `json:"doc"`
+	Values []EnumValue `json:"values"`
+}
+
+// EnumValue is a named value of an enum. Text is its name in String and
+// in JSON.
+type EnumValue struct {
+	Name  string `json:"name"`
+	Value int    `json:"value"`
+	Text  string `json:"text"`
+	Doc   string `json:"doc"`
+}
+
+// Message is a revision of a MID.
+type Message struct {
+	MID      int      `json:"mid"`
+	Revision int      `json:"revision"`
+	Title    string   `json:"title"`
+	Doc      []string `json:"doc"`
+	Fields   []Field  `json:"fields"`
+}
+
+// Field is a field of a message or of a record of a repeated group. Type is
+// one of int, float, string, bool, time, binary, group or the name of an
+// enum. A group holds Record values described by Fields; a group or binary
+// block without a width repeats Count times with Size bytes each. A field
+// added by a later revision has the range of revisions it exists in, e.g.
+// "2-", and is a pointer when it is a single value so its absence shows.
+type Field struct {
+	Name   string   `json:"name"`
+	ID     string   `json:"id"`
+	Type   string   `json:"type"`
+	Width  int      `json:"width"`
+	Scale  int      `json:"scale"`
+	Count  string   `json:"count"`
+	Size   int      `json:"size"`
+	Rev    string   `json:"rev"`
+	Record string   `json:"record"`
+	Doc    []string `json:"doc"`
+	Fields []Field  `json:"fields"`
+}
+
+// field is a spec field placed at its position.
+type field struct {
+	Field
+	idStart int
+	start   int
+	end     int
+	records []field
+}
+
+func main() {
+	specPath := flag.String("spec", "mids.json", "spec file")
+	out := flag.String("out", ".", "output directory")
+	flag.Parse()
+	if err := run(*specPath, *out); err != nil {
+		fmt.Fprintln(os.Stderr, "midgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(specPath, out string) error {
+	raw, err := os.ReadFile(specPath)
+	if err != nil {
+		return err
+	}
+	var spec Spec
+	if err := json.Unmarshal(raw, &spec); err != nil {
+		return fmt.Errorf("%s: %w", specPath, err)
+	}
+	if spec.Package == "" {
+		spec.Package = "mid"
+	}
+	g := &generator{spec: spec, enums: map[string]Enum{}}
+	for _, e := range spec.Enums {
+		g.enums[e.Name] = e
+	}
+	files, err := g.generate()
+	if err != nil {
+		return err
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(out, name), src, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type generator struct {
+	spec  Spec
+	enums map[string]Enum
+}
+
+type layout struct {
+	msg    Message
+	fields []field
+}
+
+func (g *generator) generate() (map[string][]byte, error) {
+	byMID := map[int][]layout{}
+	var mids []int
+	for _, msg := range g.spec.Messages {
+		fields, err := g.place(msg.Fields, dataStart)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", typeName(msg), err)
+		}
+		if _, ok := byMID[msg.MID]; !ok {
+			mids = append(mids, msg.MID)
+		}
+		byMID[msg.MID] = append(byMID[msg.MID], layout{msg: msg, fields: fields})
+	}
+	sort.Ints(mids)
+
+	files := map[string][]byte{}
+	var all []layout
+	for _, mid := range mids {
+		layouts := byMID[mid]
+		sort.Slice(layouts, func(i, j int) bool { return layouts[i].msg.Revision < layouts[j].msg.Revision })
+		all = append(all, layouts...)
+		src, err := g.types(layouts)
+		if err != nil {
+			return nil, err
+		}
+		files[fmt.Sprintf("mid%04d.go", mid)] = src
+	}
+	if len(g.spec.Enums) > 0 {
+		src, err := g.enumTypes()
+		if err != nil {
+			return nil, err
+		}
+		files["enum_gen.go"] = src
+	}
+	src, err := g.registry(all)
+	if err != nil {
+		return nil, err
+	}
+	files["registry_gen.go"] = src
+	if src, err = g.tests(all); err != nil {
+		return nil, err
+	}
+	files["mid_gen_test.go"] = src
+	return files, nil
+}
+
+// place assigns positions to fields starting at pos. The parameter ID of a
+// field takes the bytes right before it. Nothing can follow a block whose
+// length depends on a count.
+func (g *generator) place(specs []Field, pos int) ([]field, error) {
+	fields := make([]field, 0, len(specs))
+	variable := ""
+	for _, s := range specs {
+		if variable != "" {
+			return nil, fmt.Errorf("field %s follows variable length field %s", s.Name, variable)
+		}
+		f := field{Field: s}
+		if s.ID != "" {
+			f.idStart = pos
+			pos += len(s.ID)
+		}
+		f.start = pos
+		switch {
+		case s.Type == "group":
+			if s.Record == "" || s.Count == "" {
+				return nil, fmt.Errorf("group %s needs a record and a count", s.Name)
+			}
+			records, err := g.place(s.Fields, 1)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", s.Record, err)
+			}
+			f.records = records
+			if f.Size == 0 && len(records) > 0 {
+				f.Size = records[len(records)-1].end
+			}
+			variable = s.Name
+		case s.Type == "binary" && s.Width == 0:
+			if s.Count == "" || s.Size == 0 {
+				return nil, fmt.Errorf("binary %s needs a width or a count and size", s.Name)
+			}
+			variable = s.Name
+		default:
+			if _, err := g.goType(s); err != nil {
+				return nil, err
+			}
+			if s.Width <= 0 {
+				return nil, fmt.Errorf("field %s has no width", s.Name)
+			}
+			f.end = pos + s.Width - 1
+			pos = f.end + 1
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+func (g *generator) goType(f Field) (string, error) {
+	t, err := g.valueType(f)
+	if err != nil || f.Rev == "" || f.Type == "binary" || f.Type == "group" {
+		return t, err
+	}
+	return "*" + t, nil
+}
+
+func (g *generator) valueType(f Field) (string, error) {
+	switch f.Type {
+	case "int":
+		return "int", nil
+	case "float":
+		return "float64", nil
+	case "string":
+		return "string", nil
+	case "bool":
+		return "bool", nil
+	case "time":
+		return "time.Time", nil
+	case "binary":
+		return "[]byte", nil
+	case "group":
+		return "[]" + f.Record, nil
+	}
+	if _, ok := g.enums[f.Type]; ok {
+		return f.Type, nil
+	}
+	return "", fmt.Errorf("field %s has unknown type %q", f.Name, f.Type)
+}
+
+func tag(f field) string {
+	var opts []string
+	if f.end == 0 || f.end == f.start {
+		opts = append(opts, strconv.Itoa(f.start))
+	} else {
+		opts = append(opts, fmt.Sprintf("%d-%d", f.start, f.end))
+	}
+	if f.ID != "" {
+		opts = append(opts, "id="+f.ID)
+	}
+	if f.Scale > 1 {
+		opts = append(opts, "scale="+strconv.Itoa(f.Scale))
+	}
+	if f.end == 0 {
+		opts = append(opts, "count="+f.Count)
+		if f.Size > 0 {
+			opts = append(opts, "size="+strconv.Itoa(f.Size))
+		}
+	}
+	if f.Rev != "" {
+		opts = append(opts, "rev="+f.Rev)
+	}
+	return strings.Join(opts, ",")
+}
+
+func typeName(msg Message) string {
+	return fmt.Sprintf("MID%04dREV%03d", msg.MID, msg.Revision)
+}
+
+func comment(buf *bytes.Buffer, lines []string) {
+	for _, l := range lines {
+		fmt.Fprintf(buf, "// %s\n", l)
+	}
+}
+
+func usesTime(fields []field) bool {
+	for _, f := range fields {
+		if f.Type == "time" || usesTime(f.records) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *generator) types(layouts []layout) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	fmt.Fprintf(&buf, "package %s\n\n", g.spec.Package)
+	for _, l := range layouts {
+		if usesTime(l.fields) {
+			buf.WriteString("import \"time\"\n\n")
+			break
+		}
+	}
+	for _, l := range layouts {
+		fmt.Fprintf(&buf, "// MID %04d %s\n", l.msg.MID, l.msg.Title)
+		comment(&buf, l.msg.Doc)
+		if err := g.structType(&buf, typeName(l.msg), l.fields); err != nil {
+			return nil, err
+		}
+	}
+	return format.Source(buf.Bytes())
+}
+
+func (g *generator) structType(buf *bytes.Buffer, name string, fields []field) error {
+	fmt.Fprintf(buf, "type %s struct {\n", name)
+	for _, f := range fields {
+		if f.ID != "" {
+			fmt.Fprintf(buf, "// %d-%d %s\n", f.idStart, f.idStart+len(f.ID)-1, f.ID)
+		}
+		comment(buf, f.Doc)
+		t, err := g.goType(f.Field)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(buf, "%s %s `mid:\"%s\"`\n", f.Name, t, tag(f))
+	}
+	buf.WriteString("}\n\n")
+	for _, f := range fields {
+		if f.Type == "group" {
+			if err := g.structType(buf, f.Record, f.records); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (g *generator) enumTypes() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	fmt.Fprintf(&buf, "package %s\n\nimport (\n\"fmt\"\n\"strconv\"\n)\n\n", g.spec.Package)
+	for _, e := range g.spec.Enums {
+		comment(&buf, e.Doc)
+		fmt.Fprintf(&buf, "type %s int\n\nconst (\n", e.Name)
+		names := make([]string, 0, len(e.Values))
+		for _, v := range e.Values {
+			fmt.Fprintf(&buf, "%s %s = %d", v.Name, e.Name, v.Value)
+			if v.Doc != "" {
+				fmt.Fprintf(&buf, " // %s", v.Doc)
+			}
+			buf.WriteString("\n")
+			names = append(names, v.Name)
+		}
+		buf.WriteString(")\n\n")
+
+		fmt.Fprintf(&buf, "func (v %s) String() string {\nswitch v {\n", e.Name)
+		for _, v := range e.Values {
+			fmt.Fprintf(&buf, "case %s:\nreturn %q\n", v.Name, v.Text)
+		}
+		fmt.Fprintf(&buf, "}\nreturn \"%s(\" + strconv.Itoa(int(v)) + \")\"\n}\n\n", e.Name)
+
+		fmt.Fprintf(&buf, "// Validate reports a value that is not a known %s.\n", e.Name)
+		fmt.Fprintf(&buf, "func (v %s) Validate() error {\nswitch v {\ncase %s:\nreturn nil\n}\n", e.Name, strings.Join(names, ", "))
+		fmt.Fprintf(&buf, "return fmt.Errorf(\"invalid %s %%d\", int(v))\n}\n\n", e.Name)
+
+		fmt.Fprintf(&buf, "func (v %s) MarshalText() ([]byte, error) {\n", e.Name)
+		buf.WriteString("if err := v.Validate(); err != nil {\nreturn nil, err\n}\nreturn []byte(v.String()), nil\n}\n\n")
+
+		fmt.Fprintf(&buf, "func (v *%s) UnmarshalText(text []byte) error {\nswitch string(text) {\n", e.Name)
+		for _, v := range e.Values {
+			fmt.Fprintf(&buf, "case %q:\n*v = %s\n", v.Text, v.Name)
+		}
+		fmt.Fprintf(&buf, "default:\nreturn fmt.Errorf(\"invalid %s %%q\", text)\n}\nreturn nil\n}\n\n", e.Name)
+	}
+	return format.Source(buf.Bytes())
+}
+
+func (g *generator) registry(layouts []layout) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	fmt.Fprintf(&buf, "package %s\n\n", g.spec.Package)
+	buf.WriteString("func registerGenerated() {\n")
+	for _, l := range layouts {
+		first, last, err := span(l.msg.Revision, l.fields)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", typeName(l.msg), err)
+		}
+		if first == last {
+			fmt.Fprintf(&buf, "Register[%s](%d, %d)\n", typeName(l.msg), l.msg.MID, first)
+		} else {
+			fmt.Fprintf(&buf, "RegisterRange[%s](%d, %d, %d)\n", typeName(l.msg), l.msg.MID, first, last)
+		}
+	}
+	buf.WriteString("}\n")
+	return format.Source(buf.Bytes())
+}
+
+func (g *generator) tests(layouts []layout) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	fmt.Fprintf(&buf, "package %s_test\n\n", g.spec.Package)
+	buf.WriteString("import (\n\"time\"\n\n\"github.com/rlz-buro/mid\"\n)\n\n")
+	conditional := false
+	for _, l := range layouts {
+		name := typeName(l.msg)
+		revs, err := revisions(l.msg.Revision, l.fields)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		conditional = conditional || len(revs) > 1
+		for _, rev := range revs {
+			if rev == l.msg.Revision {
+				fmt.Fprintf(&buf, "func (suite *MIDTestSuite) Test%sRoundTrip() {\n", name)
+			} else {
+				fmt.Fprintf(&buf, "func (suite *MIDTestSuite) Test%sRoundTripRev%d() {\n", name, rev)
+			}
+			fmt.Fprintf(&buf, "want := mid.%s", name)
+			if err := g.sample(&buf, l.fields, rev, 1); err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(&buf, "\nm, err := mid.NewMID(mid.Header{MID: %d, Revision: %d}, &want, mid.WithLocation(time.UTC))\n", l.msg.MID, rev)
+			buf.WriteString("suite.Require().NoError(err)\n")
+			buf.WriteString("data, err := mid.MarshalMID(m)\n")
+			buf.WriteString("suite.Require().NoError(err)\n")
+			buf.WriteString("got, err := mid.Decode(data, mid.WithLocation(time.UTC))\n")
+			buf.WriteString("suite.NoError(err)\n")
+			buf.WriteString("suite.Equal(&want, got)\n}\n\n")
+		}
+	}
+	if conditional {
+		buf.WriteString("func ref[T any](v T) *T {\nreturn &v\n}\n")
+	}
+	return format.Source(buf.Bytes())
+}
+
+// fit wraps n into 1 up to the largest number of width digits.
+func fit(n, width int) int {
+	limit := 1
+	for i := 0; i < width && limit < 1e9; i++ {
+		limit *= 10
+	}
+	return (n-1)%(limit-1) + 1
+}
+
+// revRange parses the rev of a field: "N", "N-" or "N-M". max is 0 when the
+// field exists in every later revision.
+func revRange(rev string) (min, max int, err error) {
+	lo, hi, ranged := strings.Cut(rev, "-")
+	if min, err = strconv.Atoi(lo); err != nil || min < 1 {
+		return 0, 0, fmt.Errorf("invalid rev %q", rev)
+	}
+	switch {
+	case !ranged:
+		max = min
+	case hi != "":
+		if max, err = strconv.Atoi(hi); err != nil || max < min {
+			return 0, 0, fmt.Errorf("invalid rev %q", rev)
+		}
+	}
+	return min, max, nil
+}
+
+// inRev reports whether a field with the given rev exists in revision rev.
+func inRev(f field, rev int) bool {
+	if f.Rev == "" {
+		return true
+	}
+	min, max, err := revRange(f.Rev)
+	return err == nil && rev >= min && (max == 0 || rev <= max)
+}
+
+// span is the range of revisions a type decodes: from its own revision, or
+// an earlier one a field names, to the last one a field names. last is 0 if
+// a field exists in every later revision.
+func span(rev int, fields []field) (first, last int, err error) {
+	first, last = rev, rev
+	var walk func(fields []field) error
+	walk = func(fields []field) error {
+		for _, f := range fields {
+			if f.Rev != "" {
+				min, max, err := revRange(f.Rev)
+				if err != nil {
+					return fmt.Errorf("field %s: %w", f.Name, err)
+				}
+				if min < first {
+					first = min
+				}
+				if max == 0 || last == 0 {
+					last = 0
+				} else if max > last {
+					last = max
+				}
+			}
+			if err := walk(f.records); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(fields); err != nil {
+		return 0, 0, err
+	}
+	return first, last, nil
+}
+
+// revisions lists the revisions worth a round trip test: the one of the
+// message and every bound of a rev range, so each revision dependent field
+// is set in at least one test.
+func revisions(rev int, fields []field) ([]int, error) {
+	seen := map[int]bool{rev: true}
+	revs := []int{rev}
+	var walk func(fields []field) error
+	walk = func(fields []field) error {
+		for _, f := range fields {
+			if f.Rev != "" {
+				min, max, err := revRange(f.Rev)
+				if err != nil {
+					return fmt.Errorf("field %s: %w", f.Name, err)
+				}
+				for _, r := range []int{min, max} {
+					if r > 0 && !seen[r] {
+						seen[r] = true
+						revs = append(revs, r)
+					}
+				}
+			}
+			if err := walk(f.records); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(fields); err != nil {
+		return nil, err
+	}
+	sort.Ints(revs)
+	return revs, nil
+}
+
+// sample writes a composite literal filling every field that exists in
+// revision rev with a value derived from n that fits its width, so records
+// sampled with different n differ. Count fields are set to the number of
+// sampled records.
+func (g *generator) sample(buf *bytes.Buffer, fields []field, rev, n int) error {
+	counts := map[string]int{}
+	for _, f := range fields {
+		if f.end == 0 {
+			n, err := strconv.Atoi(f.Count)
+			if err != nil {
+				n = 2
+				counts[f.Count] = n
+			}
+			counts[f.Name] = n
+		}
+	}
+	buf.WriteString("{\n")
+	for _, f := range fields {
+		if !inRev(f, rev) {
+			continue
+		}
+		fmt.Fprintf(buf, "%s: ", f.Name)
+		pointer := f.Rev != "" && f.Type != "binary" && f.Type != "group"
+		if pointer {
+			buf.WriteString("ref(")
+		}
+		switch f.Type {
+		case "int":
+			if count, ok := counts[f.Name]; ok {
+				fmt.Fprintf(buf, "%d", count)
+			} else {
+				fmt.Fprintf(buf, "%d", fit(n, f.Width))
+			}
+		case "float":
+			if f.Scale >= 10 {
+				fmt.Fprintf(buf, "%d.5", fit(n, 1))
+			} else {
+				fmt.Fprintf(buf, "%d", fit(n, 1))
+			}
+		case "string":
+			fmt.Fprintf(buf, "%q", strings.Repeat(string(rune('A'+(n-1)%26)), f.Width))
+		case "bool":
+			fmt.Fprintf(buf, "%t", n%2 == 1)
+		case "time":
+			fmt.Fprintf(buf, "time.Date(2023, 6, 22, 10, 11, %d, 0, time.UTC)", 11+fit(n, 1))
+		case "binary":
+			size := f.Width
+			if f.end == 0 {
+				size = counts[f.Name] * f.Size
+			}
+			fmt.Fprintf(buf, "[]byte(%q)", strings.Repeat(string(rune(fit(n, 2))), size))
+		case "group":
+			fmt.Fprintf(buf, "[]mid.%s{", f.Record)
+			for i := 0; i < counts[f.Name]; i++ {
+				if err := g.sample(buf, f.records, rev, i+1); err != nil {
+					return err
+				}
+				buf.WriteString(",")
+			}
+			buf.WriteString("}")
+		default:
+			e, ok := g.enums[f.Type]
+			if !ok || len(e.Values) == 0 {
+				return fmt.Errorf("field %s has unknown type %q", f.Name, f.Type)
+			}
+			fmt.Fprintf(buf, "mid.%s", e.Values[len(e.Values)-1-(n-1)%len(e.Values)].Name)
+		}
+		if pointer {
+			buf.WriteString(")")
+		}
+		buf.WriteString(",\n")
+	}
+	buf.WriteString("}")
+	return nil
+}
@@ -0,0 +1,102 @@
+package mid
+
+import "time"
+
+type decodeMode int
+
+const (
+	defaultMode decodeMode = iota
+	strictMode
+	lenientMode
+)
+
+type codecOptions struct {
+	location      *time.Location
+	length        *int
+	checkLength   bool
+	revision      int
+	blankDefaults bool
+	mode          decodeMode
+}
+
+type CodecOption func(o *codecOptions)
+
+// defaultCodecOptions is shared by the calls without options, which only
+// read it, so they don't allocate.
+var defaultCodecOptions = codecOptions{
+	location: time.Local,
+}
+
+func newCodecOptions(opts []CodecOption) *codecOptions {
+	if len(opts) == 0 {
+		return &defaultCodecOptions
+	}
+	o := &codecOptions{
+		location: time.Local,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithLocation sets the time zone of the controller's clock. Time stamps are
+// decoded in it and encoded after converting to it. It defaults to
+// time.Local.
+func WithLocation(loc *time.Location) CodecOption {
+	return func(o *codecOptions) {
+		o.location = loc
+	}
+}
+
+// WithLength makes MarshalMID write length as the header length instead of
+// the computed one, e.g. to build invalid messages in tests.
+func WithLength(length int) CodecOption {
+	return func(o *codecOptions) {
+		o.length = &length
+	}
+}
+
+// WithLengthCheck makes UnmarshalMID fail when the header length does not
+// match the size of the message.
+func WithLengthCheck() CodecOption {
+	return func(o *codecOptions) {
+		o.checkLength = true
+	}
+}
+
+// WithRevision sets the revision of the message, selecting the fields that
+// exist in it. It overrides the revision in the header when decoding.
+// Without it, Marshal writes the latest revision the type declares.
+func WithRevision(revision int) CodecOption {
+	return func(o *codecOptions) {
+		o.revision = revision
+	}
+}
+
+// WithBlankDefaults makes Marshal send numbers holding their default value,
+// or zero, as spaces, e.g. revision 1 as three spaces. Older controllers
+// reject a revision of "000" but accept the blank default of the spec.
+func WithBlankDefaults() CodecOption {
+	return func(o *codecOptions) {
+		o.blankDefaults = true
+	}
+}
+
+// WithStrict makes Unmarshal reject blank numbers and time stamps without a
+// default, numbers with anything but digits and a sign, and bytes after the
+// last field, e.g. to check captured traffic.
+func WithStrict() CodecOption {
+	return func(o *codecOptions) {
+		o.mode = strictMode
+	}
+}
+
+// WithLenient makes Unmarshal decode every field it can and return a
+// DecodeError listing the fields that failed, e.g. for controllers with
+// noisy firmware.
+func WithLenient() CodecOption {
+	return func(o *codecOptions) {
+		o.mode = lenientMode
+	}
+}
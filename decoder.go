@@ -0,0 +1,171 @@
+package mid
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// FrameError reports bytes that could not be read as a message. The
+// decoder skips them and stays usable.
+type FrameError struct {
+	Frame []byte
+	Err   error
+}
+
+func (e *FrameError) Error() string {
+	return fmt.Sprintf("invalid mid frame %q: %v", e.Frame, e.Err)
+}
+
+func (e *FrameError) Unwrap() error {
+	return e.Err
+}
+
+// Decoder reads messages from a stream. Messages are framed by the length
+// in their header, so binary data containing NUL bytes is read as a whole;
+// the NUL terminator is checked and dropped. The buffer is kept across
+// messages, so several messages arriving at once are not lost.
+type Decoder struct {
+	r    *bufio.Reader
+	next []byte
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		r: bufio.NewReaderSize(r, maxMIDLength+1),
+	}
+}
+
+// ReadFrame returns the next message without its NUL terminator. Bytes that
+// do not start a valid message are skipped up to the next NUL and reported
+// as a *FrameError; if a valid message follows garbage within those bytes it
+// is returned by the next call.
+func (d *Decoder) ReadFrame() ([]byte, error) {
+	if d.next != nil {
+		frame := d.next
+		d.next = nil
+		return frame, nil
+	}
+	header, err := d.r.Peek(headerLength)
+	if err != nil {
+		if err == io.EOF && len(header) > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if checkHeader(header) == nil {
+		length, _ := strconv.Atoi(string(header[:4]))
+		if length >= headerLength {
+			raw, err := d.peek(length + 1)
+			if err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return nil, err
+			}
+			if raw != nil && raw[length] == '\x00' {
+				frame := append([]byte{}, raw[:length]...)
+				_, err := d.r.Discard(length + 1)
+				return frame, err
+			}
+		}
+	}
+	return nil, d.resync()
+}
+
+// peek waits for n bytes of a message, or returns nil when the bytes read so
+// far hold a NUL followed by a complete valid message before the n bytes are
+// in. The length of the message is then taken for corrupted rather than
+// waiting for bytes that may never come while valid messages are held back.
+func (d *Decoder) peek(n int) ([]byte, error) {
+	for {
+		buffered := d.r.Buffered()
+		if buffered >= n {
+			return d.r.Peek(n)
+		}
+		if buffered > 0 {
+			buf, _ := d.r.Peek(buffered)
+			if followed(buf) {
+				return nil, nil
+			}
+		}
+		if _, err := d.r.Peek(buffered + 1); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// followed reports whether buf holds a NUL followed by a valid message.
+func followed(buf []byte) bool {
+	for i, b := range buf {
+		if b != '\x00' {
+			continue
+		}
+		rest := buf[i+1:]
+		if end := bytes.IndexByte(rest, '\x00'); end >= 0 && checkFrame(rest[:end]) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// resync skips bytes up to the next NUL. A valid message at the end of the
+// skipped bytes is kept for the next call.
+func (d *Decoder) resync() error {
+	data, err := d.r.ReadBytes('\x00')
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	data = data[:len(data)-1]
+	err = checkFrame(data)
+	for i := 1; i+headerLength <= len(data); i++ {
+		if checkFrame(data[i:]) == nil {
+			d.next = data[i:]
+			return &FrameError{Frame: data[:i], Err: errors.New("garbage before header")}
+		}
+	}
+	return &FrameError{Frame: data, Err: err}
+}
+
+func (d *Decoder) Decode() (MID, error) {
+	frame, err := d.ReadFrame()
+	if err != nil {
+		return MID{}, err
+	}
+	mid := MID{}
+	if err := UnmarshalMID(frame, &mid); err != nil {
+		return MID{}, err
+	}
+	return mid, nil
+}
+
+// checkFrame checks that frame starts with a header whose length and MID
+// are digits and whose length matches the frame size.
+func checkFrame(frame []byte) error {
+	if len(frame) < headerLength {
+		return fmt.Errorf("frame of %d bytes is shorter than the header", len(frame))
+	}
+	if err := checkHeader(frame); err != nil {
+		return err
+	}
+	length, _ := strconv.Atoi(string(frame[:4]))
+	if length != len(frame) {
+		return fmt.Errorf("header length %d does not match frame size %d", length, len(frame))
+	}
+	return nil
+}
+
+func checkHeader(header []byte) error {
+	for _, b := range header[:8] {
+		if b < '0' || b > '9' {
+			return fmt.Errorf("invalid header %q", header[:headerLength])
+		}
+	}
+	return nil
+}
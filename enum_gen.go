@@ -0,0 +1,212 @@
+// Code generated by midgen. DO NOT EDIT.
+
+package mid
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// TighteningStatus is the result of a tightening.
+type TighteningStatus int
+
+const (
+	TighteningNOK TighteningStatus = 0 // Tightening NOK
+	TighteningOK  TighteningStatus = 1 // Tightening OK
+)
+
+func (v TighteningStatus) String() string {
+	switch v {
+	case TighteningNOK:
+		return "NOK"
+	case TighteningOK:
+		return "OK"
+	}
+	return "TighteningStatus(" + strconv.Itoa(int(v)) + ")"
+}
+
+// Validate reports a value that is not a known TighteningStatus.
+func (v TighteningStatus) Validate() error {
+	switch v {
+	case TighteningNOK, TighteningOK:
+		return nil
+	}
+	return fmt.Errorf("invalid TighteningStatus %d", int(v))
+}
+
+func (v TighteningStatus) MarshalText() ([]byte, error) {
+	if err := v.Validate(); err != nil {
+		return nil, err
+	}
+	return []byte(v.String()), nil
+}
+
+func (v *TighteningStatus) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "NOK":
+		*v = TighteningNOK
+	case "OK":
+		*v = TighteningOK
+	default:
+		return fmt.Errorf("invalid TighteningStatus %q", text)
+	}
+	return nil
+}
+
+// TorqueStatus is the final torque compared to its limits.
+type TorqueStatus int
+
+const (
+	TorqueLow  TorqueStatus = 0 // Torque below the min limit
+	TorqueOK   TorqueStatus = 1 // Torque within the limits
+	TorqueHigh TorqueStatus = 2 // Torque above the max limit
+)
+
+func (v TorqueStatus) String() string {
+	switch v {
+	case TorqueLow:
+		return "Low"
+	case TorqueOK:
+		return "OK"
+	case TorqueHigh:
+		return "High"
+	}
+	return "TorqueStatus(" + strconv.Itoa(int(v)) + ")"
+}
+
+// Validate reports a value that is not a known TorqueStatus.
+func (v TorqueStatus) Validate() error {
+	switch v {
+	case TorqueLow, TorqueOK, TorqueHigh:
+		return nil
+	}
+	return fmt.Errorf("invalid TorqueStatus %d", int(v))
+}
+
+func (v TorqueStatus) MarshalText() ([]byte, error) {
+	if err := v.Validate(); err != nil {
+		return nil, err
+	}
+	return []byte(v.String()), nil
+}
+
+func (v *TorqueStatus) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "Low":
+		*v = TorqueLow
+	case "OK":
+		*v = TorqueOK
+	case "High":
+		*v = TorqueHigh
+	default:
+		return fmt.Errorf("invalid TorqueStatus %q", text)
+	}
+	return nil
+}
+
+// AngleStatus is the final angle compared to its limits.
+type AngleStatus int
+
+const (
+	AngleLow  AngleStatus = 0 // Angle below the min limit
+	AngleOK   AngleStatus = 1 // Angle within the limits
+	AngleHigh AngleStatus = 2 // Angle above the max limit
+)
+
+func (v AngleStatus) String() string {
+	switch v {
+	case AngleLow:
+		return "Low"
+	case AngleOK:
+		return "OK"
+	case AngleHigh:
+		return "High"
+	}
+	return "AngleStatus(" + strconv.Itoa(int(v)) + ")"
+}
+
+// Validate reports a value that is not a known AngleStatus.
+func (v AngleStatus) Validate() error {
+	switch v {
+	case AngleLow, AngleOK, AngleHigh:
+		return nil
+	}
+	return fmt.Errorf("invalid AngleStatus %d", int(v))
+}
+
+func (v AngleStatus) MarshalText() ([]byte, error) {
+	if err := v.Validate(); err != nil {
+		return nil, err
+	}
+	return []byte(v.String()), nil
+}
+
+func (v *AngleStatus) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "Low":
+		*v = AngleLow
+	case "OK":
+		*v = AngleOK
+	case "High":
+		*v = AngleHigh
+	default:
+		return fmt.Errorf("invalid AngleStatus %q", text)
+	}
+	return nil
+}
+
+// BatchStatus is the state of the batch after a tightening.
+type BatchStatus int
+
+const (
+	BatchNOK     BatchStatus = 0 // Batch NOK
+	BatchOK      BatchStatus = 1 // Batch OK
+	BatchNotUsed BatchStatus = 2 // Batch not used
+	BatchRunning BatchStatus = 3 // Batch running
+)
+
+func (v BatchStatus) String() string {
+	switch v {
+	case BatchNOK:
+		return "NOK"
+	case BatchOK:
+		return "OK"
+	case BatchNotUsed:
+		return "NotUsed"
+	case BatchRunning:
+		return "Running"
+	}
+	return "BatchStatus(" + strconv.Itoa(int(v)) + ")"
+}
+
+// Validate reports a value that is not a known BatchStatus.
+func (v BatchStatus) Validate() error {
+	switch v {
+	case BatchNOK, BatchOK, BatchNotUsed, BatchRunning:
+		return nil
+	}
+	return fmt.Errorf("invalid BatchStatus %d", int(v))
+}
+
+func (v BatchStatus) MarshalText() ([]byte, error) {
+	if err := v.Validate(); err != nil {
+		return nil, err
+	}
+	return []byte(v.String()), nil
+}
+
+func (v *BatchStatus) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "NOK":
+		*v = BatchNOK
+	case "OK":
+		*v = BatchOK
+	case "NotUsed":
+		*v = BatchNotUsed
+	case "Running":
+		*v = BatchRunning
+	default:
+		return fmt.Errorf("invalid BatchStatus %q", text)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package mid
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+)
+
+const (
+	linkAckMID         = 997
+	linkNackMID        = 998
+	maxRetransmissions = 3
+	// linkRevision is the first revision of MID 0001/0002 negotiating
+	// sequence numbers.
+	linkRevision = 6
+)
+
+// MID 0998 error codes
+const (
+	linkInvalidLength         = 1
+	linkInvalidRevision       = 2
+	linkInvalidSequenceNumber = 3
+	linkInconsistentParts     = 4
+)
+
+type linkFrame struct {
+	payload []byte
+	retries int
+}
+
+// mid0002Link is the part of MID 0002 from revision 6 on telling whether
+// the controller supports sequence numbers.
+type mid0002Link struct {
+	SequenceNumberSupport bool `mid:"176,id=12"`
+}
+
+// linkSupported reports whether a MID 0002 says the controller supports
+// sequence numbers. Older revisions do not say so and count as no.
+func linkSupported(data []byte) bool {
+	v := mid0002Link{}
+	return Unmarshal(data, &v) == nil && v.SequenceNumberSupport
+}
+
+// startLink switches link level acknowledging on once the controller has
+// answered MID 0001 with a MID 0002 supporting sequence numbers. MID 0001
+// was sent with sequence number 1.
+func (c *Client) startLink() {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.seq = 1
+	c.rseq = 0
+	c.unacked = map[int]*linkFrame{}
+	c.linkLevel.Store(true)
+}
+
+func (c *Client) stopLink() {
+	c.linkLevel.Store(false)
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.seq = 0
+	c.rseq = 0
+	c.unacked = nil
+}
+
+// stamp sets the next sequence number in an outgoing frame and keeps the
+// frame for retransmission until the controller acknowledges it. It must be
+// called with wmu held.
+func (c *Client) stamp(payload []byte) {
+	if !c.linkLevel.Load() || len(payload) < 20 {
+		return
+	}
+	switch string(payload[4:8]) {
+	case "0997", "0998":
+		return
+	}
+	c.seq = c.seq%99 + 1
+	copy(payload[16:18], fmt.Sprintf("%02d", c.seq))
+	c.unacked[c.seq] = &linkFrame{payload: payload}
+}
+
+// link handles the link level part of an incoming frame and reports whether
+// the frame should be passed on.
+func (c *Client) link(data []byte) bool {
+	mid := MID{}
+	if err := UnmarshalMID(bytes.TrimSuffix(data, []byte{'\x00'}), &mid); err != nil {
+		c.logger.Error().Err(err).Msg("Invalid mid header")
+		return false
+	}
+	seq := mid.Header.SequenceNumber
+	switch mid.Header.MID {
+	case linkAckMID:
+		c.wmu.Lock()
+		delete(c.unacked, seq)
+		c.wmu.Unlock()
+		return false
+	case linkNackMID:
+		c.retransmit(seq, mid)
+		return false
+	}
+	c.wmu.Lock()
+	expected := c.rseq%99 + 1
+	duplicate := seq == c.rseq
+	accept := c.rseq == 0 || seq == expected
+	if accept {
+		c.rseq = seq
+	}
+	c.wmu.Unlock()
+	switch {
+	case duplicate:
+		c.logger.Warn().Int("mid", mid.Header.MID).Int("seq", seq).Msg("Drop duplicate mid message")
+		c.linkAck(mid.Header.MID, seq)
+		return false
+	case !accept:
+		c.logger.Warn().Int("mid", mid.Header.MID).Int("seq", seq).Int("expected", expected).Msg("Reject out of order mid message")
+		c.linkNack(mid.Header.MID, seq, linkInvalidSequenceNumber)
+		return false
+	}
+	c.linkAck(mid.Header.MID, seq)
+	return true
+}
+
+func (c *Client) retransmit(seq int, nack MID) {
+	code := 0
+	if len(nack.Data) >= 6 {
+		fmt.Sscanf(string(nack.Data[4:6]), "%d", &code)
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	f, ok := c.unacked[seq]
+	if !ok {
+		c.logger.Warn().Int("seq", seq).Int("code", code).Msg("Negative acknowledge for unknown mid message")
+		return
+	}
+	if f.retries >= maxRetransmissions {
+		delete(c.unacked, seq)
+		c.logger.Error().Int("seq", seq).Int("code", code).Msg("Give up retransmitting mid message")
+		return
+	}
+	f.retries++
+	c.logger.Warn().Int("seq", seq).Int("code", code).Msg("Retransmit mid message")
+	if _, err := c.conn.Write(append(f.payload, '\x00')); err != nil {
+		c.logger.Error().Err(err).Msg("Failed to retransmit mid message")
+	}
+}
+
+func (c *Client) linkAck(midNumber, seq int) {
+	mid0997 := MID{
+		Header: Header{
+			MID:            linkAckMID,
+			Revision:       1,
+			SequenceNumber: seq,
+		},
+		Data: []byte(fmt.Sprintf("%04d", midNumber)),
+	}
+	c.linkReply(mid0997)
+}
+
+func (c *Client) linkNack(midNumber, seq, code int) {
+	mid0998 := MID{
+		Header: Header{
+			MID:            linkNackMID,
+			Revision:       1,
+			SequenceNumber: seq,
+		},
+		Data: []byte(fmt.Sprintf("%04d%02d", midNumber, code)),
+	}
+	c.linkReply(mid0998)
+}
+
+func (c *Client) linkReply(mid MID) {
+	payload, err := MarshalMID(mid, c.codec...)
+	if err != nil {
+		c.logger.Error().Err(err).Msg("Failed to marshal link level acknowledge")
+		return
+	}
+	if err := c.write(context.Background(), payload); err != nil {
+		c.logger.Error().Err(err).Msg("Failed to send link level acknowledge")
+	}
+}
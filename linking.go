@@ -0,0 +1,110 @@
+package mid
+
+import (
+	"bytes"
+	"fmt"
+)
+
+const (
+	maxMIDLength    = 9999
+	maxMessageParts = 9
+	headerLength    = 20
+)
+
+// SplitMID splits a message whose length overflows 9999 bytes into linked
+// parts. Each part carries the header of v with its own length, the number of
+// parts and its part number. A message that fits is returned as it is.
+func SplitMID(v MID) ([]MID, error) {
+	if headerLength+len(v.Data) <= maxMIDLength {
+		return []MID{v}, nil
+	}
+	size := maxMIDLength - headerLength
+	n := (len(v.Data) + size - 1) / size
+	if n > maxMessageParts {
+		return nil, fmt.Errorf("message too long: %d bytes need %d parts but at most %d are possible", len(v.Data), n, maxMessageParts)
+	}
+	parts := make([]MID, 0, n)
+	for i := 0; i < n; i++ {
+		end := (i + 1) * size
+		if end > len(v.Data) {
+			end = len(v.Data)
+		}
+		part := MID{Header: v.Header, Data: v.Data[i*size : end]}
+		part.Header.Length = headerLength + len(part.Data)
+		part.Header.NumberOfMessageParts = n
+		part.Header.MessagePartNumber = i + 1
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+// MarshalMIDParts marshals v, split into linked parts if it is too long for
+// a single message.
+func MarshalMIDParts(v MID) ([][]byte, error) {
+	parts, err := SplitMID(v)
+	if err != nil {
+		return nil, err
+	}
+	raw := make([][]byte, 0, len(parts))
+	for _, part := range parts {
+		data, err := MarshalMID(part)
+		if err != nil {
+			return nil, err
+		}
+		raw = append(raw, data)
+	}
+	return raw, nil
+}
+
+// Assembler joins the parts of linked messages into one message.
+type Assembler struct {
+	pending map[int]*linked
+}
+
+type linked struct {
+	parts  int
+	frames [][]byte
+}
+
+func NewAssembler() *Assembler {
+	return &Assembler{
+		pending: map[int]*linked{},
+	}
+}
+
+// Add takes a frame and reports whether it completes a message. A message
+// that is not linked is complete right away. A joined message keeps the
+// header of its first part followed by the data of all parts.
+func (a *Assembler) Add(frame []byte) ([]byte, bool, error) {
+	frame = bytes.TrimSuffix(frame, []byte{'\x00'})
+	mid := MID{}
+	if err := UnmarshalMID(frame, &mid); err != nil {
+		return nil, false, err
+	}
+	n, i := mid.Header.NumberOfMessageParts, mid.Header.MessagePartNumber
+	if n <= 1 {
+		return frame, true, nil
+	}
+	if i < 1 || i > n {
+		return nil, false, fmt.Errorf("invalid message part %d of %d for mid %d", i, n, mid.Header.MID)
+	}
+	l, ok := a.pending[mid.Header.MID]
+	if !ok {
+		l = &linked{parts: n}
+		a.pending[mid.Header.MID] = l
+	}
+	if l.parts != n || i != len(l.frames)+1 {
+		delete(a.pending, mid.Header.MID)
+		return nil, false, fmt.Errorf("unexpected message part %d of %d for mid %d after %d of %d", i, n, mid.Header.MID, len(l.frames), l.parts)
+	}
+	l.frames = append(l.frames, frame)
+	if i < n {
+		return nil, false, nil
+	}
+	delete(a.pending, mid.Header.MID)
+	joined := append([]byte{}, l.frames[0][:headerLength]...)
+	for _, f := range l.frames {
+		joined = append(joined, f[headerLength:]...)
+	}
+	return joined, true, nil
+}
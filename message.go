@@ -0,0 +1,62 @@
+package mid
+
+import (
+	"bytes"
+	"context"
+)
+
+// Message is a pushed MID decoded into T. Err is set when the frame could
+// not be decoded; Raw always holds the frame as received.
+type Message[T any] struct {
+	Header Header
+	Value  T
+	Raw    []byte
+	Err    error
+
+	ack func(ctx context.Context) error
+}
+
+// Ack confirms the handling of a message received on a subscription with
+// the AckOnConfirm policy. It does nothing for any other message.
+func (m Message[T]) Ack() error {
+	return m.AckContext(context.Background())
+}
+
+func (m Message[T]) AckContext(ctx context.Context) error {
+	if m.ack == nil {
+		return nil
+	}
+	return m.ack(ctx)
+}
+
+// Decoded turns a raw subscription channel into a channel of decoded
+// messages. The returned channel is closed when in is closed.
+func Decoded[T any](in <-chan []byte, opts ...CodecOption) <-chan Message[T] {
+	return decoded[T](in, nil, opts)
+}
+
+func decoded[T any](in <-chan []byte, ack func(ctx context.Context) error, opts []CodecOption) <-chan Message[T] {
+	out := make(chan Message[T])
+	go func() {
+		defer close(out)
+		for raw := range in {
+			msg := DecodeMessage[T](raw, opts...)
+			msg.ack = ack
+			out <- msg
+		}
+	}()
+	return out
+}
+
+func DecodeMessage[T any](raw []byte, opts ...CodecOption) Message[T] {
+	msg := Message[T]{Raw: raw}
+	data := bytes.TrimSuffix(raw, []byte{'\x00'})
+	mid := MID{}
+	if err := UnmarshalMID(data, &mid, opts...); err != nil {
+		msg.Err = err
+		return msg
+	}
+	msg.Header = mid.Header
+	msg.Err = Unmarshal(data, &msg.Value, opts...)
+	return msg
+}
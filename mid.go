@@ -1,13 +1,40 @@
 package mid
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"math"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 )
 
-const midTagName = "mid"
+const (
+	midTagName = "mid"
+	// timeLayout is the Open Protocol time stamp format YYYY-MM-DD:HH:MM:SS.
+	timeLayout = "2006-01-02:15:04:05"
+)
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	validatorType   = reflect.TypeOf((*Validator)(nil)).Elem()
+	marshalerType   = reflect.TypeOf((*FieldMarshaler)(nil)).Elem()
+	unmarshalerType = reflect.TypeOf((*FieldUnmarshaler)(nil)).Elem()
+)
+
+// FieldMarshaler is implemented by types that encode themselves. The
+// encoding must be exactly width bytes, the size of the field.
+type FieldMarshaler interface {
+	MarshalMIDField(width int) ([]byte, error)
+}
+
+// FieldUnmarshaler is implemented by types that decode themselves from the
+// bytes of their field, blank ones included.
+type FieldUnmarshaler interface {
+	UnmarshalMIDField(data []byte) error
+}
 
 type MID struct {
 	Header Header
@@ -25,122 +52,676 @@ type Header struct {
 	// 	The revision of the MID is specified by three ASCII digits (‘0’...’9’).
 	// The MID Revision is unique per MID and is used in case different versions are available for the same MID. Using the revision number the integrator can subscribe or ask for different versions of the same MID. By default the MID revision number is three spaces long.
 	// If the initial MID Revision (revision 1) is required there is three different ways to get it, either send three spaces or 000 or 001.
-	Revision int `mid:"9-11"`
+	Revision int `mid:"9-11,default=1"`
 	// 	ONLY FOR SUBSCRIPTION MIDs.
 	// The No Ack Flag is used when setting a subscription. If the No Ack flag is not set in a subscription it means that the subscriber will acknowledge each “push” message sent by the controller (reliable mode).
 	// If set, the controller will only push out the information required without waiting for a receive acknowledgement from the subscriber (unreliable mode).
 	// Note! NOT USED WHEN USING SEQUENCE NUMBER HANDLING
 	NoAckFlag bool `mid:"12"`
 	// The station the message is addressed to in the case of controller with multi-station configuration. The station ID is 2 byte long and is specified by two ASCII digits (‘0’...’9’). Two spaces are considered as station 1 (default value).
-	StationID int `mid:"13-14"`
+	StationID int `mid:"13-14,default=1"`
 	// The spindle the message is addressed to in the case several spindles are connected to the same controller. The spindle ID is 2 bytes long and is specified by two ASCII digits (‘0’...’9’). Two spaces are considered as spindle 1 (default value).
-	SpindleID int `mid:"15-16"`
+	SpindleID int `mid:"15-16,default=1"`
 	// 	From OP Spec. 2.0. 1-99-1. For acknowledging on “Link Level” with MIDs 0997 and 0998.
 	// Not used if space or zero and not 1-99.
 	// At communication restart MID 0001/MID 0002 it must be set to one and info in MID 0002 is telling if possible to use or not. It is backward compatible and If used it will substitute the No Ack flag and all special subscription data messages ACK MIDs.
-	SequenceNumber int `mid:"17-18"`
+	SequenceNumber int `mid:"17-18,default=0"`
 	// 	From OP spec. 2.0. Linking function can be up to 9 = possible to send 9*9999 bytes messages. ~ 90 kB.
 	// Used when the message length is overflowing the max length of 9999.Not used if space or zero.
-	NumberOfMessageParts int `mid:"19"`
+	NumberOfMessageParts int `mid:"19,default=0"`
 	// 	From OP spec. 2.0. Linking function, can be 1- 9 at message length > 9999.
 	// Not used if space or zero
-	MessagePartNumber int `mid:"20"`
+	MessagePartNumber int `mid:"20,default=0"`
 }
 
-func MarshalMID(v MID) ([]byte, error) {
-	header, err := Marshal(&v.Header)
+// MarshalMID marshals the header followed by the data. The header length is
+// computed from the data unless it is overridden with WithLength.
+func MarshalMID(v MID, opts ...CodecOption) ([]byte, error) {
+	o := newCodecOptions(opts)
+	v.Header.Length = headerLength + len(v.Data)
+	if o.length != nil {
+		v.Header.Length = *o.length
+	}
+	raw, err := MarshalAppend(make([]byte, 0, headerLength+len(v.Data)), &v.Header, opts...)
 	if err != nil {
 		return nil, err
 	}
-	data := v.Data
-	return append(header, data...), nil
+	return append(raw, v.Data...), nil
 }
 
-func Marshal(v any) ([]byte, error) {
-	raw := []byte{}
+// Marshal writes every field of v at the position declared in its mid tag.
+// Numbers are padded with leading zeros, strings with trailing spaces and
+// bytes not covered by any field are filled with spaces. A value wider than
+// its field is an error. A nil pointer field is sent blank, as is a number
+// holding its default with WithBlankDefaults. Other types can implement
+// FieldMarshaler and FieldUnmarshaler. Fields of other
+// revisions than the one set with WithRevision are left out; without it
+// the latest revision the type declares is written.
+func Marshal(v any, opts ...CodecOption) ([]byte, error) {
 	rv := reflect.ValueOf(v).Elem()
-	rt := reflect.TypeOf(v).Elem()
-	for i := 0; i < rt.NumField(); i++ {
-		field := rt.Field(i)
-		tag := field.Tag.Get(midTagName)
-		s, e, err := parseTag(tag)
-		if err != nil {
-			return nil, fmt.Errorf("invalid mid tag: %w", err)
+	p, err := planOf(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+	o := newCodecOptions(opts)
+	return marshal(make([]byte, 0, p.size), 0, rv, p, o, p.marshalRevision(o))
+}
+
+// MarshalAppend appends the encoding of v to dst and returns the extended
+// buffer. The positions of the mid tags count from the end of dst, so a
+// message is built by appending its data fields to a buffer holding the
+// header, reusing the buffer across messages.
+func MarshalAppend(dst []byte, v any, opts ...CodecOption) ([]byte, error) {
+	rv := reflect.ValueOf(v).Elem()
+	p, err := planOf(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+	o := newCodecOptions(opts)
+	return marshal(dst, len(dst), rv, p, o, p.marshalRevision(o))
+}
+
+// marshal writes the fields of rv that exist in revision rev at their
+// positions after base.
+func marshal(dst []byte, base int, rv reflect.Value, p *plan, o *codecOptions, rev int) ([]byte, error) {
+	var num [32]byte
+	for i := range p.fields {
+		f := &p.fields[i]
+		if !f.in(rev) {
+			continue
+		}
+		fv := rv.Field(f.index)
+		if f.pointer {
+			if fv.IsNil() {
+				dst = f.slot(dst, base, f.end-f.start+1)
+				fill(dst[base+f.start-1:base+f.end], ' ')
+				continue
+			}
+			fv = fv.Elem()
 		}
-		var v string
-		switch field.Type.Kind() {
-		case reflect.Int:
-			v = strconv.Itoa(int(rv.Field(i).Int()))
-			if len(v) < e-s+1 {
-				v = strings.Repeat("0", e-s+1-len(v)) + v
+		switch f.kind {
+		case kindBinary:
+			n, err := f.length(rv)
+			if err != nil {
+				return nil, err
+			}
+			if block := fv.Bytes(); len(block) != n {
+				return nil, fmt.Errorf("binary %s has %d bytes but %d are declared", f.name, len(block), n)
 			}
-		case reflect.Bool:
-			if rv.Field(i).Bool() {
-				v = "1"
-			} else {
-				v = "0"
+			if n == 0 {
+				continue
 			}
-		case reflect.String:
-			v = rv.Field(i).String()
+			dst = f.slot(dst, base, n)
+			copy(dst[base+f.start-1:], fv.Bytes())
+			continue
+		case kindGroup:
+			n, err := f.length(rv)
+			if err != nil {
+				return nil, err
+			}
+			if n != fv.Len() {
+				return nil, fmt.Errorf("repeated group %s has %d records but its count is %d", f.name, fv.Len(), n)
+			}
+			if n == 0 {
+				continue
+			}
+			dst = f.slot(dst, base, n*f.size)
+			for j := 0; j < n; j++ {
+				rec := base + f.start - 1 + j*f.size
+				if dst, err = marshal(dst, rec, fv.Index(j), f.record, o, rev); err != nil {
+					return nil, fmt.Errorf("record %d of %s: %w", j+1, f.name, err)
+				}
+			}
+			continue
+		}
+		width := f.end - f.start + 1
+		dst = f.slot(dst, base, width)
+		b := dst[base+f.start-1 : base+f.end]
+		var v []byte
+		switch f.kind {
+		case kindInt:
+			if n := int(fv.Int()); o.blankDefaults && f.hasDefault && (n == 0 || n == f.def) {
+				fill(b, ' ')
+				continue
+			}
+			v = strconv.AppendInt(num[:0], fv.Int(), 10)
+		case kindFloat:
+			var err error
+			if v, err = appendScaledFloat(num[:0], fv.Float(), f.decimals); err != nil {
+				return nil, fmt.Errorf("invalid %s value: %w", f.name, err)
+			}
+		case kindBool:
+			v = append(num[:0], '0')
+			if fv.Bool() {
+				v[0] = '1'
+			}
+		case kindString:
+			str := fv.String()
+			if len(str) > width {
+				return nil, f.overflow(str)
+			}
+			fill(b[copy(b, str):], ' ')
+			continue
+		case kindCustom:
+			m, ok := customValue(fv, marshalerType).(FieldMarshaler)
+			if !ok {
+				return nil, fmt.Errorf("%s of type %s is not a FieldMarshaler", f.name, fv.Type())
+			}
+			raw, err := m.MarshalMIDField(width)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s value: %w", f.name, err)
+			}
+			if len(raw) != width {
+				return nil, f.overflow(string(raw))
+			}
+			copy(b, raw)
+			continue
+		case kindTime:
+			t := fv.Interface().(time.Time)
+			if t.IsZero() {
+				fill(b, ' ')
+				continue
+			}
+			if v = t.In(o.location).AppendFormat(num[:0], timeLayout); len(v) != width {
+				return nil, f.overflow(string(v))
+			}
+		}
+		if !putNumber(b, v) {
+			return nil, f.overflow(string(v))
 		}
-		raw = append(raw, []byte(v)...)
 	}
-	return raw, nil
+	return dst, nil
+}
+
+// slot extends dst with spaces to hold n bytes at the position of f after
+// base and writes the parameter ID of f in front of them.
+func (f *fieldPlan) slot(dst []byte, base, n int) []byte {
+	for end := base + f.start - 1 + n; len(dst) < end; {
+		dst = append(dst, ' ')
+	}
+	copy(dst[base+f.start-1-len(f.id):], f.id)
+	return dst
 }
 
-func UnmarshalMID(data []byte, v *MID) error {
+func (f *fieldPlan) overflow(v string) error {
+	return fmt.Errorf("value %q of %s does not fit in %d bytes at %d-%d", v, f.name, f.end-f.start+1, f.start, f.end)
+}
+
+func fill(b []byte, c byte) {
+	for i := range b {
+		b[i] = c
+	}
+}
+
+// putNumber writes v to b left padded with zeros, after the sign of a
+// negative number. It reports whether v fits.
+func putNumber(b, v []byte) bool {
+	if len(v) > len(b) {
+		return false
+	}
+	i := 0
+	if len(v) > 0 && v[0] == '-' {
+		b[0] = '-'
+		i, v = 1, v[1:]
+	}
+	for ; i < len(b)-len(v); i++ {
+		b[i] = '0'
+	}
+	copy(b[i:], v)
+	return true
+}
+
+// NewMID builds a message from header and the data fields of v, which are
+// declared at their positions in the whole message. Only the fields of the
+// revision in the header are written.
+func NewMID(header Header, v any, opts ...CodecOption) (MID, error) {
+	rev := header.Revision
+	if rev < 1 {
+		rev = 1
+	}
+	raw, err := Marshal(v, append([]CodecOption{WithRevision(rev)}, opts...)...)
+	if err != nil {
+		return MID{}, err
+	}
+	mid := MID{Header: header}
+	if len(raw) > headerLength {
+		mid.Data = raw[headerLength:]
+	}
+	mid.Header.Length = headerLength + len(mid.Data)
+	return mid, nil
+}
+
+func UnmarshalMID(data []byte, v *MID, opts ...CodecOption) error {
 	if l := len(data); l < 20 {
 		return fmt.Errorf("invalid header: header size should be 20 bytes but actual header has only %d", l)
 	}
-	if err := Unmarshal(data, &v.Header); err != nil {
+	if err := Unmarshal(data[:headerLength], &v.Header, opts...); err != nil {
 		return err
 	}
+	if o := newCodecOptions(opts); o.checkLength && v.Header.Length != len(data) {
+		return fmt.Errorf("invalid header: length %d does not match message size %d", v.Header.Length, len(data))
+	}
 	if len(data) > 20 {
 		v.Data = data[20:]
 	}
 	return nil
 }
 
-func Unmarshal(data []byte, v any) error {
+// Validator is implemented by field types with a restricted set of values,
+// such as the status enums. Unmarshal reports a value that is not valid.
+type Validator interface {
+	Validate() error
+}
+
+// FieldError is a field that could not be decoded. Field is its name, with
+// the record for a field of a repeated group, e.g. "Jobs[2].JobID", and
+// Offset the 0-based position of its bytes in the message.
+type FieldError struct {
+	Field  string
+	Offset int
+	Err    error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("field %s at offset %d: %v", e.Field, e.Offset, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeError lists every field a lenient Unmarshal could not decode.
+type DecodeError []*FieldError
+
+func (e DecodeError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e DecodeError) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// Unmarshal decodes the fields of v from the positions declared in their
+// mid tags. Blank numbers are skipped, or take their default, and the first
+// field that fails is returned as a *FieldError. WithStrict and WithLenient
+// change how far it goes.
+func Unmarshal(data []byte, v any, opts ...CodecOption) error {
 	rv := reflect.ValueOf(v).Elem()
-	rt := reflect.TypeOf(v).Elem()
-	for i := 0; i < rt.NumField(); i++ {
-		field := rt.Field(i)
-		tag := field.Tag.Get(midTagName)
-		s, e, err := parseTag(tag)
-		if err != nil {
-			return fmt.Errorf("invalid mid tag %q: %w", tag, err)
-		}
-		if s < 1 || e > len(data)+1 {
-			return fmt.Errorf("mid values should be %d <= i <= %d: start - %d end - %d", 1, len(data)+1, s, e)
-		}
-		token := data[s-1 : e]
-		if string(token) == strings.Repeat(" ", len(token)) {
+	p, err := planOf(rv.Type())
+	if err != nil {
+		return err
+	}
+	o := newCodecOptions(opts)
+	d := decoder{o: o, rev: revision(data, p, o)}
+	end, err := d.unmarshal(data, 0, "", rv, p)
+	if err != nil {
+		return err
+	}
+	if o.mode == strictMode && end < len(data) {
+		return fmt.Errorf("%d trailing bytes at offset %d", len(data)-end, end)
+	}
+	if len(d.errs) > 0 {
+		return d.errs
+	}
+	return nil
+}
+
+// revision is the revision a message is decoded as: the one set with
+// WithRevision or else the one in its header, where blank or zero means 1.
+// It is 0, meaning any, if the type has no revision dependent fields or
+// data has no header.
+func revision(data []byte, p *plan, o *codecOptions) int {
+	if !p.conditional {
+		return 0
+	}
+	if o.revision > 0 {
+		return o.revision
+	}
+	if len(data) < headerLength {
+		return 0
+	}
+	rev, err := parseInt(data[8:11])
+	if err != nil || rev < 1 {
+		return 1
+	}
+	return rev
+}
+
+// decoder is the state of an Unmarshal call.
+type decoder struct {
+	o   *codecOptions
+	rev int
+	// errs collects the field errors in lenient mode.
+	errs DecodeError
+}
+
+// fail reports a field error. In lenient mode it is collected and decoding
+// goes on.
+func (d *decoder) fail(path string, f *fieldPlan, offset int, err error) error {
+	fe := &FieldError{Field: path + f.name, Offset: offset, Err: err}
+	if d.o.mode == lenientMode {
+		d.errs = append(d.errs, fe)
+		return nil
+	}
+	return fe
+}
+
+// unmarshal decodes the fields of rv that exist in revision d.rev and
+// resets the others to their zero value, so absent pointer fields are nil.
+// data starts at offset base of the message and path prefixes the names of
+// its fields. It returns the end of the decoded bytes.
+func (d *decoder) unmarshal(data []byte, base int, path string, rv reflect.Value, p *plan) (int, error) {
+	end := 0
+	for i := range p.fields {
+		f := &p.fields[i]
+		fv := rv.Field(f.index)
+		if !f.in(d.rev) {
+			fv.Set(reflect.Zero(fv.Type()))
 			continue
 		}
-		switch field.Type.Kind() {
-		case reflect.Int:
-			val, err := strconv.Atoi(strings.TrimSpace(string(token)))
+		offset := base + f.start - 1
+		if f.id != "" {
+			if f.start-1 > len(data) {
+				if err := d.fail(path, f, offset, fmt.Errorf("needs bytes from %d but data has %d", f.start, len(data))); err != nil {
+					return 0, err
+				}
+				continue
+			}
+			if id := data[f.start-1-len(f.id) : f.start-1]; string(id) != f.id {
+				err := fmt.Errorf("misaligned: expected parameter id %q but got %q", f.id, string(id))
+				if err := d.fail(path, f, offset-len(f.id), err); err != nil {
+					return 0, err
+				}
+				continue
+			}
+		}
+		switch f.kind {
+		case kindBinary:
+			n, err := f.length(rv)
+			if err == nil && f.start-1+n > len(data) {
+				err = fmt.Errorf("%d bytes do not fit in %d bytes", n, len(data))
+			}
 			if err != nil {
-				return fmt.Errorf("invalid data token %q: %w", string(token), err)
+				if err := d.fail(path, f, offset, err); err != nil {
+					return 0, err
+				}
+				continue
+			}
+			fv.SetBytes(append([]byte{}, data[f.start-1:f.start-1+n]...))
+			if e := f.start - 1 + n; e > end {
+				end = e
+			}
+			continue
+		case kindGroup:
+			n, err := f.length(rv)
+			if err == nil && f.start-1+n*f.size > len(data) {
+				err = fmt.Errorf("%d records of %d bytes do not fit in %d bytes", n, f.size, len(data))
 			}
-			rv.Field(i).SetInt(int64(val))
-		case reflect.Bool:
-			val, err := strconv.Atoi(string(token))
 			if err != nil {
-				return fmt.Errorf("invalid data token %q: %w", string(token), err)
+				if err := d.fail(path, f, offset, err); err != nil {
+					return 0, err
+				}
+				continue
+			}
+			records := reflect.MakeSlice(fv.Type(), n, n)
+			for j := 0; j < n; j++ {
+				s := f.start - 1 + j*f.size
+				prefix := path + f.name + "[" + strconv.Itoa(j+1) + "]."
+				if _, err := d.unmarshal(data[s:s+f.size], base+s, prefix, records.Index(j), f.record); err != nil {
+					return 0, err
+				}
+			}
+			fv.Set(records)
+			if e := f.start - 1 + n*f.size; e > end {
+				end = e
+			}
+			continue
+		}
+		if f.end > len(data) {
+			if err := d.fail(path, f, offset, fmt.Errorf("needs bytes %d-%d but data has %d", f.start, f.end, len(data))); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if f.end > end {
+			end = f.end
+		}
+		if err := d.decode(data[f.start-1:f.end], fv, f); err != nil {
+			if err := d.fail(path, f, offset, err); err != nil {
+				return 0, err
 			}
-			rv.Field(i).SetBool(val != 0)
-		case reflect.String:
-			rv.Field(i).SetString(string(token))
+		}
+	}
+	return end, nil
+}
+
+// decode decodes the token of a single value field.
+func (d *decoder) decode(token []byte, fv reflect.Value, f *fieldPlan) error {
+	strict := d.o.mode == strictMode
+	if isBlank(token) && (f.pointer || f.kind != kindCustom) {
+		switch {
+		case f.pointer:
+			fv.Set(reflect.Zero(fv.Type()))
+		case f.hasDefault:
+			fv.SetInt(int64(f.def))
+		case strict && f.kind != kindString:
+			return errors.New("blank value")
+		}
+		return nil
+	}
+	if f.pointer {
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		fv = fv.Elem()
+	}
+	switch f.kind {
+	case kindInt, kindFloat, kindBool:
+		if strict && !isDigits(token) {
+			return fmt.Errorf("invalid data token %q: not a number", string(token))
+		}
+		val, err := parseInt(token)
+		if err != nil {
+			return fmt.Errorf("invalid data token %q: %w", string(token), err)
+		}
+		switch f.kind {
+		case kindInt:
+			fv.SetInt(int64(val))
+		case kindFloat:
+			fv.SetFloat(float64(val) / float64(f.scale))
 		default:
-			return fmt.Errorf("%q type is not supported", field.Type.Kind().String())
+			fv.SetBool(val != 0)
+		}
+	case kindString:
+		fv.SetString(string(token))
+	case kindCustom:
+		u, ok := customValue(fv, unmarshalerType).(FieldUnmarshaler)
+		if !ok {
+			return fmt.Errorf("type %s is not a FieldUnmarshaler", fv.Type())
+		}
+		if err := u.UnmarshalMIDField(token); err != nil {
+			return err
+		}
+	case kindTime:
+		val, err := time.ParseInLocation(timeLayout, string(token), d.o.location)
+		if err != nil {
+			return fmt.Errorf("invalid time stamp %q: %w", string(token), err)
+		}
+		fv.Set(reflect.ValueOf(val))
+	}
+	if f.validate {
+		if err := addressOf(fv).(Validator).Validate(); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
-func parseTag(tag string) (int, int, error) {
+// customValue returns fv, or its address when only the pointer implements
+// iface, as an interface value.
+func customValue(fv reflect.Value, iface reflect.Type) any {
+	if !fv.Type().Implements(iface) && fv.CanAddr() {
+		fv = fv.Addr()
+	}
+	return fv.Interface()
+}
+
+// addressOf returns the address of fv as an interface value, which unlike
+// fv.Interface does not copy it, or fv itself when it is not addressable.
+func addressOf(fv reflect.Value) any {
+	if fv.CanAddr() {
+		return fv.Addr().Interface()
+	}
+	return fv.Interface()
+}
+
+// isDigits reports whether token is a number without padding spaces.
+func isDigits(token []byte) bool {
+	if len(token) > 1 && token[0] == '-' {
+		token = token[1:]
+	}
+	for _, c := range token {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isBlank(token []byte) bool {
+	for _, c := range token {
+		if c != ' ' {
+			return false
+		}
+	}
+	return true
+}
+
+// maxIntDigits is the number of digits that always fit in an int.
+const maxIntDigits = strconv.IntSize*3/10 - 1
+
+// parseInt parses a decimal number surrounded by spaces without allocating.
+// Anything else is left to strconv.Atoi for its error.
+func parseInt(token []byte) (int, error) {
+	token = bytes.TrimSpace(token)
+	digits, neg := token, false
+	if len(digits) > 0 && (digits[0] == '-' || digits[0] == '+') {
+		digits, neg = digits[1:], digits[0] == '-'
+	}
+	if len(digits) == 0 || len(digits) > maxIntDigits {
+		return strconv.Atoi(string(token))
+	}
+	n := 0
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return strconv.Atoi(string(token))
+		}
+		n = n*10 + int(c-'0')
+	}
+	if neg {
+		n = -n
+	}
+	return n, nil
+}
+
+type fieldTag struct {
+	start int
+	end   int
+	// scale is the factor a float value is multiplied by on the wire.
+	scale int
+	// id is the parameter ID preceding the field in a data field.
+	id string
+	// count is the number of records of a repeated group, either fixed or
+	// given by an earlier field of the struct.
+	count string
+	// size is the byte size of one record of a repeated group.
+	size int
+	// minRev and maxRev are the first and last revision the field exists
+	// in, 0 when unbounded.
+	minRev int
+	maxRev int
+	// def is the value of a blank number, set if hasDefault.
+	def        int
+	hasDefault bool
+}
+
+// parseTag parses a mid tag: the 1-based byte position or range of the field
+// followed by comma separated options, e.g. "117-122,id=15,scale=100". The
+// parameter ID takes the bytes right before the field. A repeated group is
+// declared by the position of its first record and its count, e.g.
+// "23,count=NumberOfJobs,size=2". A []byte field is a raw binary block of
+// either the declared range or count times size bytes. A field added by a
+// later revision declares the revisions it exists in, e.g. "rev=2-" or
+// "rev=2-4". A number sent blank to mean a default value declares it, e.g.
+// "default=1".
+func parseTag(tag string) (fieldTag, error) {
+	t := fieldTag{scale: 1}
+	opts := strings.Split(tag, ",")
+	start, end, err := parseRange(opts[0])
+	if err != nil {
+		return t, err
+	}
+	t.start, t.end = start, end
+	for _, opt := range opts[1:] {
+		key, value, _ := strings.Cut(opt, "=")
+		switch key {
+		case "scale":
+			t.scale, err = strconv.Atoi(value)
+			if err != nil {
+				return t, fmt.Errorf("invalid scale %q: %w", value, err)
+			}
+			if t.scale < 1 || strings.TrimRight(value, "0") != "1" {
+				return t, fmt.Errorf("scale should be a power of 10: %q", value)
+			}
+		case "id":
+			if _, err := strconv.Atoi(value); err != nil {
+				return t, fmt.Errorf("invalid parameter id %q: %w", value, err)
+			}
+			if t.start-len(value) < 1 {
+				return t, fmt.Errorf("no room for parameter id %q before position %d", value, t.start)
+			}
+			t.id = value
+		case "count":
+			t.count = value
+		case "size":
+			t.size, err = strconv.Atoi(value)
+			if err != nil || t.size < 1 {
+				return t, fmt.Errorf("invalid record size %q", value)
+			}
+		case "default":
+			if t.def, err = strconv.Atoi(value); err != nil {
+				return t, fmt.Errorf("invalid default %q: %w", value, err)
+			}
+			t.hasDefault = true
+		case "rev":
+			min, max, ok := strings.Cut(value, "-")
+			if t.minRev, err = strconv.Atoi(min); err != nil || t.minRev < 1 {
+				return t, fmt.Errorf("invalid revision range %q", value)
+			}
+			switch {
+			case !ok:
+				t.maxRev = t.minRev
+			case max != "":
+				if t.maxRev, err = strconv.Atoi(max); err != nil || t.maxRev < t.minRev {
+					return t, fmt.Errorf("invalid revision range %q", value)
+				}
+			}
+		default:
+			return t, fmt.Errorf("unknown mid tag option %q", opt)
+		}
+	}
+	return t, nil
+}
+
+func parseRange(tag string) (int, int, error) {
 	var (
 		start int
 		end   int
@@ -168,3 +749,51 @@ func parseTag(tag string) (int, int, error) {
 	}
 	return start, end, nil
 }
+
+// appendScaledFloat appends v multiplied by 10^decimals as an integer.
+// Decimals beyond them are truncated on the decimal representation of v, so
+// 12.34 with 2 decimals is always 1234.
+func appendScaledFloat(dst []byte, v float64, decimals int) ([]byte, error) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil, fmt.Errorf("%v can not be encoded", v)
+	}
+	var buf [32]byte
+	s := strconv.AppendFloat(buf[:0], v, 'f', -1, 64)
+	start := len(dst)
+	if s[0] == '-' {
+		dst = append(dst, '-')
+		s = s[1:]
+	}
+	whole, frac := s, s[:0]
+	if i := bytes.IndexByte(s, '.'); i >= 0 {
+		whole, frac = s[:i], s[i+1:]
+	}
+	digits := len(dst)
+	for i := 0; i < len(whole)+decimals; i++ {
+		c := byte('0')
+		if i < len(whole) {
+			c = whole[i]
+		} else if i-len(whole) < len(frac) {
+			c = frac[i-len(whole)]
+		}
+		if c == '0' && len(dst) == digits {
+			continue
+		}
+		dst = append(dst, c)
+	}
+	if len(dst) == digits {
+		return append(dst[:start], '0'), nil
+	}
+	return dst, nil
+}
+
+// isBinary reports whether t is a raw binary block.
+func isBinary(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+}
+
+// isGroup reports whether t is a repeated group: a slice of records whose
+// fields are declared relative to the start of each record.
+func isGroup(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Struct && t.Elem() != timeType
+}
@@ -0,0 +1,81 @@
+// Code generated by midgen. DO NOT EDIT.
+
+package mid
+
+import "time"
+
+// MID 0101 Multi-spindle result
+// The multi-spindle result of a synchronized tightening, with the status of each spindle.
+type MID0101REV001 struct {
+	// 21-22 01
+	// The number of spindles taking part in the multi-spindle result. 2 ASCII digits. Range: 01-50.
+	NumberOfSpindles int `mid:"23-24,id=01"`
+	// 25-26 02
+	// The VIN number is 25 bytes long and is specified by 25 ASCII characters.
+	VINNumber string `mid:"27-51,id=02"`
+	// 52-53 03
+	// The Job ID is two bytes long and specified by two ASCII digits. Range: 00-99.
+	JobID int `mid:"54-55,id=03"`
+	// 56-57 04
+	// The parameter set ID is three bytes long and specified by three ASCII digits. Range: 000-999.
+	ParameterSetID int `mid:"58-60,id=04"`
+	// 61-62 05
+	// The batch size is four bytes long and specified by four ASCII digits. Range: 0000-9999.
+	BatchSize int `mid:"63-66,id=05"`
+	// 67-68 06
+	// The batch counter is four bytes long and specified by four ASCII digits. Range: 0000-9999.
+	BatchCounter int `mid:"69-72,id=06"`
+	// 73-74 07
+	// The batch status is specified by one ASCII character. 0=batch NOK, 1=batch OK, 2=batch not used
+	BatchStatus BatchStatus `mid:"75,id=07"`
+	// 76-77 08
+	// The torque min limit is multiplied by 100 and sent as an integer (2 decimals truncated). Six ASCII digits.
+	TorqueMinLimit float64 `mid:"78-83,id=08,scale=100"`
+	// 84-85 09
+	// The torque max limit is multiplied by 100 and sent as an integer (2 decimals truncated). Six ASCII digits.
+	TorqueMaxLimit float64 `mid:"86-91,id=09,scale=100"`
+	// 92-93 10
+	// The torque final target is multiplied by 100 and sent as an integer (2 decimals truncated). Six ASCII digits.
+	TorqueFinalTarget float64 `mid:"94-99,id=10,scale=100"`
+	// 100-101 11
+	// The angle min value in degrees. Five ASCII digits. Range: 00000-99999.
+	AngleMin int `mid:"102-106,id=11"`
+	// 107-108 12
+	// The angle max value in degrees. Five ASCII digits. Range: 00000-99999.
+	AngleMax int `mid:"109-113,id=12"`
+	// 114-115 13
+	// The target angle value in degrees. Five ASCII digits. Range: 00000-99999.
+	FinalAngleTarget int `mid:"116-120,id=13"`
+	// 121-122 14
+	// Time stamp for the last change in the current parameter set settings (YYYY-MM-DD:HH:MM:SS).
+	DateTimeOfLastChangeInParameterSetSettings time.Time `mid:"123-141,id=14"`
+	// 142-143 15
+	// Time stamp for the multi-spindle result (YYYY-MM-DD:HH:MM:SS).
+	TimeStamp time.Time `mid:"144-162,id=15"`
+	// 163-164 16
+	// The tightening ID of the synchronized tightening. Five ASCII digits. Range: 00000-65535.
+	SyncTighteningID int `mid:"165-169,id=16"`
+	// 170-171 17
+	// The overall status of all the spindles. 0=NOK, 1=OK
+	SyncOverallStatus TighteningStatus `mid:"172,id=17"`
+	// 173-174 18
+	// The status of each spindle, 18 bytes per spindle.
+	SpindleStatus []MID0101Spindle `mid:"175,id=18,count=NumberOfSpindles,size=18"`
+}
+
+type MID0101Spindle struct {
+	// The spindle number. Two ASCII digits. Range: 01-99.
+	SpindleNumber int `mid:"1-2"`
+	// The channel ID of the spindle. Two ASCII digits. Range: 01-20.
+	ChannelID int `mid:"3-4"`
+	// The overall status of the spindle. 0=NOK, 1=OK
+	OverallStatus TighteningStatus `mid:"5"`
+	// The torque status of the spindle. 0=Low, 1=OK, 2=High
+	TorqueStatus TorqueStatus `mid:"6"`
+	// The torque result multiplied by 100 (2 decimals truncated). Six ASCII digits.
+	Torque float64 `mid:"7-12,scale=100"`
+	// The angle status of the spindle. 0=Low, 1=OK, 2=High
+	AngleStatus AngleStatus `mid:"13"`
+	// The angle result in degrees. Five ASCII digits. Range: 00000-99999.
+	Angle int `mid:"14-18"`
+}
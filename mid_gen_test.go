@@ -0,0 +1,157 @@
+// Code generated by midgen. DO NOT EDIT.
+
+package mid_test
+
+import (
+	"time"
+
+	"github.com/rlz-buro/mid"
+)
+
+func (suite *MIDTestSuite) TestMID0011REV001RoundTrip() {
+	want := mid.MID0011REV001{
+		NumberOfParameterSets: 2,
+		ParameterSets: []mid.MID0011ParameterSet{{
+			ParameterSetID: 1,
+		}, {
+			ParameterSetID: 2,
+		}},
+	}
+	m, err := mid.NewMID(mid.Header{MID: 11, Revision: 1}, &want, mid.WithLocation(time.UTC))
+	suite.Require().NoError(err)
+	data, err := mid.MarshalMID(m)
+	suite.Require().NoError(err)
+	got, err := mid.Decode(data, mid.WithLocation(time.UTC))
+	suite.NoError(err)
+	suite.Equal(&want, got)
+}
+
+func (suite *MIDTestSuite) TestMID0031REV001RoundTrip() {
+	want := mid.MID0031REV001{
+		NumberOfJobs: 2,
+		Jobs: []mid.MID0031Job{{
+			JobID: 1,
+		}, {
+			JobID: 2,
+		}},
+	}
+	m, err := mid.NewMID(mid.Header{MID: 31, Revision: 1}, &want, mid.WithLocation(time.UTC))
+	suite.Require().NoError(err)
+	data, err := mid.MarshalMID(m)
+	suite.Require().NoError(err)
+	got, err := mid.Decode(data, mid.WithLocation(time.UTC))
+	suite.NoError(err)
+	suite.Equal(&want, got)
+}
+
+func (suite *MIDTestSuite) TestMID0035REV001RoundTrip() {
+	want := mid.MID0035REV001{
+		JobID:           1,
+		JobStatus:       1,
+		JobBatchMode:    1,
+		JobBatchSize:    1,
+		JobBatchCounter: 1,
+		TimeStamp:       time.Date(2023, 6, 22, 10, 11, 12, 0, time.UTC),
+	}
+	m, err := mid.NewMID(mid.Header{MID: 35, Revision: 1}, &want, mid.WithLocation(time.UTC))
+	suite.Require().NoError(err)
+	data, err := mid.MarshalMID(m)
+	suite.Require().NoError(err)
+	got, err := mid.Decode(data, mid.WithLocation(time.UTC))
+	suite.NoError(err)
+	suite.Equal(&want, got)
+}
+
+func (suite *MIDTestSuite) TestMID0052REV001RoundTrip() {
+	want := mid.MID0052REV001{
+		VINNumber: "AAAAAAAAAAAAAAAAAAAAAAAAA",
+	}
+	m, err := mid.NewMID(mid.Header{MID: 52, Revision: 1}, &want, mid.WithLocation(time.UTC))
+	suite.Require().NoError(err)
+	data, err := mid.MarshalMID(m)
+	suite.Require().NoError(err)
+	got, err := mid.Decode(data, mid.WithLocation(time.UTC))
+	suite.NoError(err)
+	suite.Equal(&want, got)
+}
+
+func (suite *MIDTestSuite) TestMID0061REV001RoundTrip() {
+	want := mid.MID0061REV001{
+		CellID:               1,
+		ChannelID:            1,
+		TorqueControllerName: "AAAAAAAAAAAAAAAAAAAAAAAAA",
+		VINNumber:            "AAAAAAAAAAAAAAAAAAAAAAAAA",
+		JobID:                1,
+		ParameterSetID:       1,
+		BatchSize:            1,
+		BatchCounter:         1,
+		TighteningStatus:     mid.TighteningOK,
+		TorqueStatus:         mid.TorqueHigh,
+		AngleStatus:          mid.AngleHigh,
+		TorqueMinLimit:       1.5,
+		TorqueMaxLimit:       1.5,
+		TorqueFinalTarget:    1.5,
+		Torque:               1.5,
+		AngleMin:             1,
+		AngleMax:             1,
+		FinalAngleTarget:     1,
+		Angle:                1,
+		TimeStamp:            time.Date(2023, 6, 22, 10, 11, 12, 0, time.UTC),
+		DateTimeOfLastChangeInParameterSetSettings: time.Date(2023, 6, 22, 10, 11, 12, 0, time.UTC),
+		BatchStatus:  mid.BatchRunning,
+		TighteningID: 1,
+	}
+	m, err := mid.NewMID(mid.Header{MID: 61, Revision: 1}, &want, mid.WithLocation(time.UTC))
+	suite.Require().NoError(err)
+	data, err := mid.MarshalMID(m)
+	suite.Require().NoError(err)
+	got, err := mid.Decode(data, mid.WithLocation(time.UTC))
+	suite.NoError(err)
+	suite.Equal(&want, got)
+}
+
+func (suite *MIDTestSuite) TestMID0101REV001RoundTrip() {
+	want := mid.MID0101REV001{
+		NumberOfSpindles:  2,
+		VINNumber:         "AAAAAAAAAAAAAAAAAAAAAAAAA",
+		JobID:             1,
+		ParameterSetID:    1,
+		BatchSize:         1,
+		BatchCounter:      1,
+		BatchStatus:       mid.BatchRunning,
+		TorqueMinLimit:    1.5,
+		TorqueMaxLimit:    1.5,
+		TorqueFinalTarget: 1.5,
+		AngleMin:          1,
+		AngleMax:          1,
+		FinalAngleTarget:  1,
+		DateTimeOfLastChangeInParameterSetSettings: time.Date(2023, 6, 22, 10, 11, 12, 0, time.UTC),
+		TimeStamp:         time.Date(2023, 6, 22, 10, 11, 12, 0, time.UTC),
+		SyncTighteningID:  1,
+		SyncOverallStatus: mid.TighteningOK,
+		SpindleStatus: []mid.MID0101Spindle{{
+			SpindleNumber: 1,
+			ChannelID:     1,
+			OverallStatus: mid.TighteningOK,
+			TorqueStatus:  mid.TorqueHigh,
+			Torque:        1.5,
+			AngleStatus:   mid.AngleHigh,
+			Angle:         1,
+		}, {
+			SpindleNumber: 2,
+			ChannelID:     2,
+			OverallStatus: mid.TighteningNOK,
+			TorqueStatus:  mid.TorqueOK,
+			Torque:        2.5,
+			AngleStatus:   mid.AngleOK,
+			Angle:         2,
+		}},
+	}
+	m, err := mid.NewMID(mid.Header{MID: 101, Revision: 1}, &want, mid.WithLocation(time.UTC))
+	suite.Require().NoError(err)
+	data, err := mid.MarshalMID(m)
+	suite.Require().NoError(err)
+	got, err := mid.Decode(data, mid.WithLocation(time.UTC))
+	suite.NoError(err)
+	suite.Equal(&want, got)
+}
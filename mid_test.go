@@ -1,7 +1,14 @@
 package mid_test
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 
@@ -40,3 +47,577 @@ func (suite *MIDTestSuite) TestMarshal() {
 	suite.Len(raw, len(data))
 	suite.Equal(data, raw)
 }
+
+func (suite *MIDTestSuite) TestDecodeMessage() {
+	data := []byte("00630035001000000000010502103004001005000306" + "2023-06-22:10:11:12\x00")
+	msg := mid.DecodeMessage[mid.MID0035REV001](data, mid.WithLocation(time.UTC))
+	suite.NoError(msg.Err)
+	suite.Equal(35, msg.Header.MID)
+	suite.Equal(5, msg.Value.JobID)
+	suite.Equal(1, msg.Value.JobStatus)
+	suite.Equal(10, msg.Value.JobBatchSize)
+	suite.Equal(3, msg.Value.JobBatchCounter)
+	suite.Equal(time.Date(2023, 6, 22, 10, 11, 12, 0, time.UTC), msg.Value.TimeStamp)
+
+	msg = mid.DecodeMessage[mid.MID0035REV001](data[:40])
+	suite.Error(msg.Err)
+	suite.Equal(data[:40], msg.Raw)
+}
+
+func (suite *MIDTestSuite) TestMessageLinking() {
+	m := mid.MID{
+		Header: mid.Header{
+			MID:      900,
+			Revision: 1,
+		},
+		Data: bytes.Repeat([]byte("0123456789"), 2500),
+	}
+	parts, err := mid.MarshalMIDParts(m)
+	suite.NoError(err)
+	suite.Len(parts, 3)
+	suite.Equal("9999", string(parts[0][:4]))
+	suite.Equal("31", string(parts[0][18:20]))
+	suite.Equal("33", string(parts[2][18:20]))
+
+	asm := mid.NewAssembler()
+	for i, part := range parts {
+		joined, ok, err := asm.Add(part)
+		suite.NoError(err)
+		suite.Equal(i == len(parts)-1, ok)
+		if ok {
+			suite.Equal(m.Data, joined[20:])
+		}
+	}
+
+	_, _, err = asm.Add(parts[1])
+	suite.Error(err)
+
+	_, err = mid.SplitMID(mid.MID{Data: make([]byte, 10*9999)})
+	suite.Error(err)
+}
+
+func (suite *MIDTestSuite) TestDecoder() {
+	stream := "00200001001000000000\x00" +
+		"00200002001000000000\x00" +
+		"garbage00209999001000000000\x00" +
+		"00300005001000000000\x00" +
+		"0024000500100000000000\x00" +
+		"002400050010000000000001\x00"
+	dec := mid.NewDecoder(strings.NewReader(stream + "0020"))
+
+	m, err := dec.Decode()
+	suite.NoError(err)
+	suite.Equal(1, m.Header.MID)
+	m, err = dec.Decode()
+	suite.NoError(err)
+	suite.Equal(2, m.Header.MID)
+
+	var ferr *mid.FrameError
+	_, err = dec.Decode()
+	suite.ErrorAs(err, &ferr)
+	suite.Equal([]byte("garbage"), ferr.Frame)
+	m, err = dec.Decode()
+	suite.NoError(err)
+	suite.Equal(9999, m.Header.MID)
+
+	_, err = dec.Decode()
+	suite.ErrorAs(err, &ferr)
+	_, err = dec.Decode()
+	suite.ErrorAs(err, &ferr)
+	frame, err := dec.ReadFrame()
+	suite.NoError(err)
+	suite.Equal("002400050010000000000001", string(frame))
+	_, err = dec.Decode()
+	suite.ErrorIs(err, io.ErrUnexpectedEOF)
+}
+
+func (suite *MIDTestSuite) TestDecoderCorruptLength() {
+	r, w := io.Pipe()
+	defer w.Close()
+	go func() {
+		w.Write([]byte("05009999001000000000\x00"))
+		w.Write([]byte("00209999001000000000\x00"))
+	}()
+	dec := mid.NewDecoder(r)
+
+	frames := make(chan error, 2)
+	go func() {
+		for i := 0; i < 2; i++ {
+			_, err := dec.ReadFrame()
+			frames <- err
+		}
+	}()
+	var ferr *mid.FrameError
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-frames:
+			if i == 0 {
+				suite.ErrorAs(err, &ferr)
+			} else {
+				suite.NoError(err)
+			}
+		case <-time.After(time.Second):
+			suite.FailNow("valid frame held back by a corrupt length")
+		}
+	}
+}
+
+func (suite *MIDTestSuite) TestScaledFloat() {
+	type torque struct {
+		Min    float64 `mid:"1-6,scale=100"`
+		Target float64 `mid:"7-12,scale=100"`
+		Angle  float64 `mid:"13-17,scale=10"`
+	}
+	v := torque{}
+	suite.NoError(mid.Unmarshal([]byte("001234012340   55"), &v))
+	suite.Equal(12.34, v.Min)
+	suite.Equal(123.4, v.Target)
+	suite.Equal(5.5, v.Angle)
+
+	raw, err := mid.Marshal(&torque{Min: 12.349, Target: 0.29, Angle: 99.99})
+	suite.NoError(err)
+	suite.Equal("00123400002900999", string(raw))
+
+	type invalid struct {
+		Torque float64 `mid:"1-6,scale=3"`
+	}
+	suite.Error(mid.Unmarshal([]byte("000001"), &invalid{}))
+}
+
+func (suite *MIDTestSuite) TestTime() {
+	type stamp struct {
+		TimeStamp time.Time `mid:"1-19"`
+	}
+	loc := time.FixedZone("CET", 3600)
+	v := stamp{}
+	suite.NoError(mid.Unmarshal([]byte("2023-06-22:10:11:12"), &v, mid.WithLocation(loc)))
+	suite.True(time.Date(2023, 6, 22, 9, 11, 12, 0, time.UTC).Equal(v.TimeStamp))
+
+	raw, err := mid.Marshal(&v, mid.WithLocation(time.UTC))
+	suite.NoError(err)
+	suite.Equal("2023-06-22:09:11:12", string(raw))
+
+	v = stamp{}
+	suite.NoError(mid.Unmarshal([]byte(strings.Repeat(" ", 19)), &v))
+	suite.True(v.TimeStamp.IsZero())
+	raw, err = mid.Marshal(&v)
+	suite.NoError(err)
+	suite.Equal(strings.Repeat(" ", 19), string(raw))
+
+	suite.Error(mid.Unmarshal([]byte("2023-13-22:10:11:12"), &v))
+}
+
+func (suite *MIDTestSuite) TestParameterID() {
+	v := mid.MID0061REV001{
+		CellID:               1,
+		ChannelID:            2,
+		TorqueControllerName: "Controller               ",
+		VINNumber:            "VIN                      ",
+		ParameterSetID:       3,
+		BatchSize:            10,
+		BatchCounter:         4,
+		TighteningStatus:     1,
+		Torque:               12.34,
+		TimeStamp:            time.Date(2023, 6, 22, 10, 11, 12, 0, time.UTC),
+		TighteningID:         42,
+	}
+	data, err := mid.Marshal(&v, mid.WithLocation(time.UTC))
+	suite.NoError(err)
+	suite.Len(data, 231)
+	suite.Equal("010001", string(data[20:26]))
+	frame := append([]byte("02310061001000000000"), data[20:]...)
+
+	w := mid.MID0061REV001{}
+	suite.NoError(mid.Unmarshal(frame, &w, mid.WithLocation(time.UTC)))
+	suite.Equal(v, w)
+
+	frame[26] = '9'
+	err = mid.Unmarshal(frame, &w)
+	suite.ErrorContains(err, "ChannelID")
+}
+
+func (suite *MIDTestSuite) TestStatusEnums() {
+	v := mid.MID0061REV001{
+		TighteningStatus: mid.TighteningOK,
+		TorqueStatus:     mid.TorqueHigh,
+		BatchStatus:      mid.BatchRunning,
+	}
+	suite.Equal("High", v.TorqueStatus.String())
+	suite.Equal("AngleStatus(5)", mid.AngleStatus(5).String())
+
+	raw, err := json.Marshal(v)
+	suite.NoError(err)
+	suite.Contains(string(raw), `"TighteningStatus":"OK","TorqueStatus":"High","AngleStatus":"Low"`)
+	w := mid.MID0061REV001{}
+	suite.NoError(json.Unmarshal(raw, &w))
+	suite.Equal(v, w)
+	suite.Error(json.Unmarshal([]byte(`{"BatchStatus":"Done"}`), &w))
+
+	data, err := mid.Marshal(&v)
+	suite.NoError(err)
+	suite.NoError(mid.Unmarshal(data, &w))
+	data[110] = '7'
+	err = mid.Unmarshal(data, &w)
+	suite.ErrorContains(err, "TorqueStatus")
+}
+
+func (suite *MIDTestSuite) TestMarshalPositions() {
+	type fields struct {
+		Name   string `mid:"3-8"`
+		Number int    `mid:"12-15,id=01"`
+		Delta  int    `mid:"16-19"`
+	}
+	raw, err := mid.Marshal(&fields{Name: "abc", Number: 42, Delta: -7})
+	suite.NoError(err)
+	suite.Equal("  abc    010042-007", string(raw))
+
+	_, err = mid.Marshal(&fields{Number: 12345})
+	suite.ErrorContains(err, "Number")
+	_, err = mid.Marshal(&fields{Name: "toolongname"})
+	suite.ErrorContains(err, "Name")
+}
+
+func (suite *MIDTestSuite) TestHeaderLength() {
+	m := mid.MID{
+		Header: mid.Header{MID: 108, Revision: 1},
+		Data:   []byte("1"),
+	}
+	raw, err := mid.MarshalMID(m)
+	suite.NoError(err)
+	suite.Equal("002101080010000000001", string(raw))
+
+	raw, err = mid.MarshalMID(m, mid.WithLength(20))
+	suite.NoError(err)
+	suite.Equal("002001080010000000001", string(raw))
+
+	suite.NoError(mid.UnmarshalMID(raw, &mid.MID{}))
+	suite.Error(mid.UnmarshalMID(raw, &mid.MID{}, mid.WithLengthCheck()))
+
+	m, err = mid.NewMID(mid.Header{MID: 35, Revision: 1}, &mid.MID0035REV001{JobID: 1})
+	suite.NoError(err)
+	raw, err = mid.MarshalMID(m)
+	suite.NoError(err)
+	suite.Equal("00630035001000000000010102", string(raw[:26]))
+	suite.NoError(mid.UnmarshalMID(raw, &mid.MID{}, mid.WithLengthCheck()))
+}
+
+func (suite *MIDTestSuite) TestRepeatedGroup() {
+	data := []byte("00350011001000000000004001002005010")
+	v := mid.MID0011REV001{}
+	suite.NoError(mid.Unmarshal(data, &v))
+	suite.Equal(4, v.NumberOfParameterSets)
+	suite.Equal([]mid.MID0011ParameterSet{{1}, {2}, {5}, {10}}, v.ParameterSets)
+
+	m, err := mid.NewMID(mid.Header{MID: 11, Revision: 1}, &v)
+	suite.NoError(err)
+	raw, err := mid.MarshalMID(m)
+	suite.NoError(err)
+	suite.Equal(data[:4], raw[:4])
+	suite.Equal(data[20:], raw[20:])
+
+	v.NumberOfParameterSets = 5
+	_, err = mid.Marshal(&v)
+	suite.Error(err)
+	suite.Error(mid.Unmarshal(data[:30], &v))
+
+	var late struct {
+		Sets  []mid.MID0011ParameterSet `mid:"4,count=Count,size=3"`
+		Count int                       `mid:"1-3"`
+	}
+	suite.ErrorContains(mid.Unmarshal([]byte("001"), &late), "Count")
+}
+
+func (suite *MIDTestSuite) TestBinary() {
+	type trace struct {
+		NumberOfSamples int    `mid:"23-26,id=01"`
+		Samples         []byte `mid:"29,id=02,count=NumberOfSamples,size=2"`
+	}
+	v := trace{NumberOfSamples: 3, Samples: []byte{0x00, 0x01, 0xff, 0x00, 0x00, 0x10}}
+	m, err := mid.NewMID(mid.Header{MID: 900, Revision: 1}, &v)
+	suite.NoError(err)
+	raw, err := mid.MarshalMID(m)
+	suite.NoError(err)
+	suite.Equal("0034", string(raw[:4]))
+
+	stream := append(append(raw, 0), []byte("00209999001000000000\x00")...)
+	dec := mid.NewDecoder(bytes.NewReader(stream))
+	frame, err := dec.ReadFrame()
+	suite.NoError(err)
+	w := trace{}
+	suite.NoError(mid.Unmarshal(frame, &w))
+	suite.Equal(v, w)
+	m, err = dec.Decode()
+	suite.NoError(err)
+	suite.Equal(9999, m.Header.MID)
+
+	v.NumberOfSamples = 2
+	_, err = mid.Marshal(&v)
+	suite.Error(err)
+}
+
+func (suite *MIDTestSuite) TestMultiSpindleResult() {
+	data := "02100101001000000000" + "0102" + "02" + fmt.Sprintf("%-25s", "VIN1") + "0301" + "04005" +
+		"050010" + "060003" + "072" + "08001000" + "09002000" + "10001500" + "1100010" + "1200090" + "1300045" +
+		"142023-06-22:10:11:12" + "152023-06-22:10:12:13" + "1600042" + "171" +
+		"18" + "0101" + "1" + "1" + "001510" + "1" + "00044" + "0202" + "0" + "2" + "002100" + "0" + "00030"
+	v, err := mid.Decode([]byte(data), mid.WithLocation(time.UTC))
+	suite.Require().NoError(err)
+	result, ok := v.(*mid.MID0101REV001)
+	suite.Require().True(ok)
+	suite.Equal("VIN1", strings.TrimSpace(result.VINNumber))
+	suite.Equal(15.0, result.TorqueFinalTarget)
+	suite.Equal(42, result.SyncTighteningID)
+	suite.Equal([]mid.MID0101Spindle{
+		{SpindleNumber: 1, ChannelID: 1, OverallStatus: mid.TighteningOK, TorqueStatus: mid.TorqueOK, Torque: 15.1, AngleStatus: mid.AngleOK, Angle: 44},
+		{SpindleNumber: 2, ChannelID: 2, OverallStatus: mid.TighteningNOK, TorqueStatus: mid.TorqueHigh, Torque: 21, AngleStatus: mid.AngleLow, Angle: 30},
+	}, result.SpindleStatus)
+}
+
+func (suite *MIDTestSuite) TestDecode() {
+	v, err := mid.Decode([]byte("002600040010000000000060" + "09\x00"))
+	suite.NoError(err)
+	suite.Equal(&mid.MID0004REV001{MIDNumber: 60, ErrorCode: mid.LastTighteningResultSubscriptionAlreadyExists}, v)
+
+	v, err = mid.Decode([]byte("004700520020000000000112345678901234567890123456789"))
+	suite.NoError(err)
+	suite.IsType(&mid.MID{}, v)
+
+	v, err = mid.Decode([]byte("00240071001000000000E123"))
+	suite.NoError(err)
+	suite.Equal(&mid.MID{Header: mid.Header{Length: 24, MID: 71, Revision: 1}, Data: []byte("E123")}, v)
+
+	type custom struct {
+		Value int `mid:"21-24"`
+	}
+	mid.Register[custom](71, 1)
+	defer mid.Unregister(71, 1)
+	v, err = mid.Decode([]byte("002400710010000000000123"))
+	suite.NoError(err)
+	suite.Equal(&custom{Value: 123}, v)
+
+	mid.RegisterRange[custom](72, 2, 0)
+	defer mid.Unregister(72, 2)
+	v, err = mid.Decode([]byte("002400720010000000000123"))
+	suite.NoError(err)
+	suite.IsType(&mid.MID{}, v)
+	v, err = mid.Decode([]byte("002400720070000000000123"))
+	suite.NoError(err)
+	suite.Equal(&custom{Value: 123}, v)
+}
+
+func (suite *MIDTestSuite) TestRevision() {
+	type result struct {
+		CellID int    `mid:"23-26,id=01"`
+		Torque *int   `mid:"29-34,id=02,rev=2-"`
+		Name   string `mid:"37-40,id=03,rev=3"`
+	}
+	v := result{}
+	suite.NoError(mid.Unmarshal([]byte("00260061001000000000010001"), &v))
+	suite.Equal(result{CellID: 1}, v)
+
+	torque := 1234
+	m, err := mid.NewMID(mid.Header{MID: 61, Revision: 2}, &result{CellID: 1, Torque: &torque, Name: "abcd"})
+	suite.NoError(err)
+	raw, err := mid.MarshalMID(m)
+	suite.NoError(err)
+	suite.Equal("0034006100200000000001000102001234", string(raw))
+	suite.NoError(mid.Unmarshal(raw, &v))
+	suite.Equal(result{CellID: 1, Torque: &torque}, v)
+
+	raw = []byte("0040006100300000000001000102      03abcd")
+	suite.NoError(mid.Unmarshal(raw, &v))
+	suite.Equal(result{CellID: 1, Name: "abcd"}, v)
+	suite.NoError(mid.Unmarshal(raw, &v, mid.WithRevision(4)))
+	suite.Equal(result{CellID: 1}, v)
+
+	raw, err = mid.Marshal(&result{CellID: 1, Torque: &torque, Name: "abcd"})
+	suite.NoError(err)
+	suite.Equal(strings.Repeat(" ", 20)+"01000102001234"+"03abcd", string(raw))
+
+	type invalid struct {
+		Value int `mid:"1-4,rev=3-2"`
+	}
+	suite.Error(mid.Unmarshal(raw, &invalid{}))
+}
+
+func (suite *MIDTestSuite) TestHeaderDefaults() {
+	m := mid.MID{}
+	suite.NoError(mid.UnmarshalMID([]byte("00200001   0        "), &m))
+	suite.Equal(mid.Header{Length: 20, MID: 1, Revision: 1, StationID: 1, SpindleID: 1}, m.Header)
+	suite.NoError(mid.UnmarshalMID([]byte("00200001000000000000"), &m))
+	suite.Equal(mid.Header{Length: 20, MID: 1}, m.Header)
+
+	m = mid.MID{Header: mid.Header{MID: 1, Revision: 1, StationID: 1, SpindleID: 2, SequenceNumber: 3}}
+	raw, err := mid.MarshalMID(m)
+	suite.NoError(err)
+	suite.Equal("00200001001001020300", string(raw))
+	raw, err = mid.MarshalMID(m, mid.WithBlankDefaults())
+	suite.NoError(err)
+	suite.Equal("00200001   0  0203  ", string(raw))
+
+	type invalid struct {
+		Name string `mid:"1-4,default=1"`
+	}
+	suite.Error(mid.Unmarshal([]byte("    "), &invalid{}))
+}
+
+func (suite *MIDTestSuite) TestDecodeModes() {
+	type result struct {
+		CellID    int    `mid:"23-26,id=01"`
+		ChannelID int    `mid:"29-30,id=02"`
+		Name      string `mid:"33-36,id=03"`
+		Torque    int    `mid:"39-44,id=04"`
+	}
+	data := "00440061001000000000" + "010001" + "0202" + "03abcd" + "04000150"
+	v := result{}
+	suite.NoError(mid.Unmarshal([]byte(data), &v, mid.WithStrict()))
+	suite.Equal(result{CellID: 1, ChannelID: 2, Name: "abcd", Torque: 150}, v)
+
+	for _, data := range []string{
+		data + "X",
+		strings.Replace(data, "0202", "02  ", 1),
+		strings.Replace(data, "0202", "02 2", 1),
+	} {
+		suite.NoError(mid.Unmarshal([]byte(data), &v))
+		suite.Error(mid.Unmarshal([]byte(data), &v, mid.WithStrict()), data)
+	}
+
+	v = result{}
+	data = strings.Replace(strings.Replace(data, "010001", "0100x1", 1), "03abcd", "09abcd", 1)
+	err := mid.Unmarshal([]byte(data), &v, mid.WithLenient())
+	var errs mid.DecodeError
+	suite.Require().ErrorAs(err, &errs)
+	suite.Len(errs, 2)
+	suite.Equal("CellID", errs[0].Field)
+	suite.Equal(22, errs[0].Offset)
+	suite.Equal("Name", errs[1].Field)
+	suite.Equal(30, errs[1].Offset)
+	suite.Equal(result{ChannelID: 2, Torque: 150}, v)
+
+	var fe *mid.FieldError
+	suite.Require().ErrorAs(mid.Unmarshal([]byte(data), &v), &fe)
+	suite.Equal("CellID", fe.Field)
+
+	err = mid.Unmarshal([]byte("00320011001000000000003001x02005"), &mid.MID0011REV001{}, mid.WithLenient())
+	suite.Require().ErrorAs(err, &fe)
+	suite.Equal("ParameterSets[2].ParameterSetID", fe.Field)
+	suite.Equal(26, fe.Offset)
+}
+
+// hexID is a custom field type sent as upper case hexadecimal digits.
+type hexID int
+
+func (h hexID) MarshalMIDField(width int) ([]byte, error) {
+	return []byte(fmt.Sprintf("%0*X", width, int(h))), nil
+}
+
+func (h *hexID) UnmarshalMIDField(data []byte) error {
+	v, err := strconv.ParseInt(strings.TrimSpace(string(data)), 16, 0)
+	*h = hexID(v)
+	return err
+}
+
+func (suite *MIDTestSuite) TestFieldMarshaler() {
+	type tool struct {
+		ID       hexID  `mid:"3-6,id=01"`
+		Previous *hexID `mid:"9-12,id=02"`
+	}
+	raw, err := mid.Marshal(&tool{ID: 0xbeef})
+	suite.NoError(err)
+	suite.Equal("01BEEF02    ", string(raw))
+
+	v := tool{}
+	suite.NoError(mid.Unmarshal([]byte("01BEEF0200FF"), &v))
+	suite.Equal(hexID(0xbeef), v.ID)
+	suite.Equal(hexID(0xff), *v.Previous)
+
+	err = mid.Unmarshal([]byte("01ZZZZ02    "), &v)
+	var fe *mid.FieldError
+	suite.Require().ErrorAs(err, &fe)
+	suite.Equal("ID", fe.Field)
+
+	_, err = mid.Marshal(&tool{ID: 0x12345})
+	suite.ErrorContains(err, "ID")
+}
+
+func (suite *MIDTestSuite) TestMarshalAppend() {
+	raw, err := mid.MarshalAppend(nil, &mid.Header{MID: 35, Revision: 1})
+	suite.NoError(err)
+	suite.Equal("00000035001000000000", string(raw))
+
+	type data struct {
+		JobID int `mid:"3-4,id=01"`
+	}
+	raw, err = mid.MarshalAppend(raw, &data{JobID: 7})
+	suite.NoError(err)
+	suite.Equal("000000350010000000000107", string(raw))
+}
+
+func (suite *MIDTestSuite) TestUnmarshalAllocs() {
+	data := []byte("00200001001000000000")
+	h := mid.Header{}
+	suite.Zero(testing.AllocsPerRun(100, func() { _ = mid.Unmarshal(data, &h) }))
+}
+
+func benchmarkResult() mid.MID0061REV001 {
+	return mid.MID0061REV001{
+		CellID:               1,
+		ChannelID:            2,
+		TorqueControllerName: "Controller",
+		VINNumber:            "VIN",
+		ParameterSetID:       3,
+		BatchSize:            10,
+		BatchCounter:         4,
+		TighteningStatus:     1,
+		Torque:               12.34,
+		TimeStamp:            time.Date(2023, 6, 22, 10, 11, 12, 0, time.UTC),
+		TighteningID:         42,
+	}
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	v := benchmarkResult()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := mid.Marshal(&v, mid.WithLocation(time.UTC)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshalAppend(b *testing.B) {
+	v := benchmarkResult()
+	buf := make([]byte, 0, 256)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var err error
+		if buf, err = mid.MarshalAppend(buf[:0], &v, mid.WithLocation(time.UTC)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	v := benchmarkResult()
+	data, err := mid.Marshal(&v, mid.WithLocation(time.UTC))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := mid.Unmarshal(data, &v, mid.WithLocation(time.UTC)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalHeader(b *testing.B) {
+	data := []byte("02310061001000000000")
+	h := mid.Header{}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := mid.Unmarshal(data, &h); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package mid
+
+import "time"
+
+type Option func(c *Client)
+
+// WithRequestTimeout bounds every command whose context carries no deadline
+// of its own, including the methods without a context argument.
+func WithRequestTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = d
+	}
+}
+
+// WithReconnect makes the client redial a lost connection, waiting backoff
+// before the first attempt and doubling it up to maxBackoff. After
+// reconnecting the communication is started again and active subscriptions
+// are restored on the same channels; commands wait until that is done. A
+// failed restore drops the connection with ErrRestore and dials again.
+func WithReconnect(backoff, maxBackoff time.Duration) Option {
+	return func(c *Client) {
+		c.backoff = backoff
+		c.maxBackoff = maxBackoff
+	}
+}
+
+// WithKeepAlive makes the client send MID 9999 whenever nothing else has been
+// sent for interval. If the echo does not arrive within timeout the link is
+// declared dead. A timeout of zero or less waits for interval, and an
+// interval of zero or less turns keep-alives off.
+func WithKeepAlive(interval, timeout time.Duration) Option {
+	return func(c *Client) {
+		if timeout <= 0 {
+			timeout = interval
+		}
+		c.keepAlive = interval
+		c.keepAliveTimeout = timeout
+	}
+}
+
+// WithConnectionStateHandler registers a func called on every connection state
+// change. It is called from the read loop and must not block.
+func WithConnectionStateHandler(f func(ConnectionEvent)) Option {
+	return func(c *Client) {
+		c.stateHandler = f
+	}
+}
+
+// WithSequenceNumbers asks the controller for link level acknowledging with
+// MID 0997/0998 when the communication is started, sending MID 0001 revision
+// 6. A controller not supporting that revision is asked again with revision
+// 1 and no sequence numbers. If the controller agrees in MID 0002,
+// outgoing messages are numbered and retransmitted on a negative acknowledge,
+// incoming ones are acknowledged and checked for duplicates and ordering, and
+// the link level acknowledge replaces the subscription data acknowledges.
+func WithSequenceNumbers() Option {
+	return func(c *Client) {
+		c.sequence = true
+	}
+}
+
+// WithUnsolicitedHandler registers a func receiving every message that is
+// neither subscribed data nor the reply to a pending command.
+func WithUnsolicitedHandler(f func(MID)) Option {
+	return func(c *Client) {
+		c.unsolicitedHandler = f
+	}
+}
+
+// WithCodec sets the codec options used to encode sent messages and to
+// decode subscribed data, e.g. WithBlankDefaults for older controllers.
+func WithCodec(opts ...CodecOption) Option {
+	return func(c *Client) {
+		c.codec = opts
+	}
+}
@@ -0,0 +1,222 @@
+package mid
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+type fieldKind int
+
+const (
+	kindInt fieldKind = iota
+	kindFloat
+	kindBool
+	kindString
+	kindTime
+	kindBinary
+	kindGroup
+	// kindCustom is a FieldMarshaler or FieldUnmarshaler.
+	kindCustom
+)
+
+// plan is the compiled layout of a struct type: its mid tags parsed and
+// checked once, so encoding only walks the fields.
+type plan struct {
+	fields []fieldPlan
+	// size is the end of the last fixed size field.
+	size int
+	// conditional is set if some field exists only in some revisions.
+	conditional bool
+	// latest is the highest revision named by a rev option.
+	latest int
+}
+
+type fieldPlan struct {
+	fieldTag
+	name  string
+	index int
+	kind  fieldKind
+	// pointer is set for a pointer to a value of kind, nil when the value
+	// is blank or absent.
+	pointer bool
+	// validate is set if the value is a Validator.
+	validate bool
+	// decimals is the number of decimals of a scaled float.
+	decimals int
+	// countField is the index of the field holding the count of a repeated
+	// group or binary block, or -1 when the count is fixed.
+	countField int
+	fixedCount int
+	// record is the plan of the records of a repeated group.
+	record *plan
+}
+
+type planEntry struct {
+	plan *plan
+	err  error
+}
+
+// plans caches the plan of every type marshaled or unmarshaled so far.
+var plans sync.Map
+
+// planOf returns the cached plan of the struct type rt, compiling it on
+// first use.
+func planOf(rt reflect.Type) (*plan, error) {
+	if e, ok := plans.Load(rt); ok {
+		return e.(planEntry).plan, e.(planEntry).err
+	}
+	p, err := compile(rt)
+	e, _ := plans.LoadOrStore(rt, planEntry{plan: p, err: err})
+	return e.(planEntry).plan, e.(planEntry).err
+}
+
+func compile(rt reflect.Type) (*plan, error) {
+	if rt.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%q type is not supported", rt.String())
+	}
+	p := &plan{fields: make([]fieldPlan, 0, rt.NumField())}
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag := field.Tag.Get(midTagName)
+		t, err := parseTag(tag)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mid tag %q of %s: %w", tag, field.Name, err)
+		}
+		if t.start < 1 || t.end < t.start {
+			return nil, fmt.Errorf("invalid mid tag %q of %s: wrong range", tag, field.Name)
+		}
+		f := fieldPlan{fieldTag: t, name: field.Name, index: i, countField: -1}
+		ft := field.Type
+		if ft.Kind() == reflect.Pointer {
+			ft, f.pointer = ft.Elem(), true
+		}
+		switch {
+		case isCustom(ft):
+			f.kind = kindCustom
+		case isBinary(ft) && !f.pointer:
+			f.kind = kindBinary
+		case isGroup(ft) && !f.pointer:
+			f.kind = kindGroup
+		case ft == timeType:
+			f.kind = kindTime
+		default:
+			switch ft.Kind() {
+			case reflect.Int:
+				f.kind = kindInt
+			case reflect.Float32, reflect.Float64:
+				f.kind = kindFloat
+				f.decimals = len(strconv.Itoa(t.scale)) - 1
+			case reflect.Bool:
+				f.kind = kindBool
+			case reflect.String:
+				f.kind = kindString
+			default:
+				return nil, fmt.Errorf("%q type of %s is not supported, it may implement FieldMarshaler and FieldUnmarshaler", field.Type.String(), field.Name)
+			}
+		}
+		if f.hasDefault && (f.kind != kindInt || f.pointer) {
+			return nil, fmt.Errorf("invalid mid tag %q of %s: default of a non int field", tag, field.Name)
+		}
+		f.validate = f.kind != kindGroup && f.kind != kindBinary && (ft.Implements(validatorType) || reflect.PointerTo(ft).Implements(validatorType))
+		if f.kind == kindGroup || (f.kind == kindBinary && t.count != "") {
+			if err := f.compileCount(rt, field); err != nil {
+				return nil, err
+			}
+		}
+		if f.kind == kindGroup {
+			if f.record, err = planOf(field.Type.Elem()); err != nil {
+				return nil, fmt.Errorf("record of %s: %w", field.Name, err)
+			}
+			if f.size == 0 {
+				f.size = f.record.size
+			}
+			p.conditional = p.conditional || f.record.conditional
+			if f.record.latest > p.latest {
+				p.latest = f.record.latest
+			}
+			if f.record.size > f.size {
+				return nil, fmt.Errorf("record of %s does not fit in %d bytes", field.Name, f.size)
+			}
+		}
+		if f.kind == kindBinary && f.size == 0 {
+			f.size = 1
+		}
+		if f.minRev > 0 {
+			p.conditional = true
+		}
+		if f.minRev > p.latest {
+			p.latest = f.minRev
+		}
+		if f.maxRev > p.latest {
+			p.latest = f.maxRev
+		}
+		if f.count == "" && f.end > p.size {
+			p.size = f.end
+		}
+		p.fields = append(p.fields, f)
+	}
+	return p, nil
+}
+
+// isCustom reports whether t encodes or decodes itself.
+func isCustom(t reflect.Type) bool {
+	pt := reflect.PointerTo(t)
+	return t.Implements(marshalerType) || pt.Implements(marshalerType) || pt.Implements(unmarshalerType)
+}
+
+func (f *fieldPlan) compileCount(rt reflect.Type, field reflect.StructField) error {
+	if f.count == "" {
+		return fmt.Errorf("repeated group %s has no count", field.Name)
+	}
+	if n, err := strconv.Atoi(f.count); err == nil {
+		f.fixedCount = n
+		return nil
+	}
+	count, ok := rt.FieldByName(f.count)
+	if !ok || len(count.Index) != 1 || count.Type.Kind() != reflect.Int {
+		return fmt.Errorf("count %q of %s is not an int field", f.count, field.Name)
+	}
+	if count.Index[0] >= f.index {
+		return fmt.Errorf("count %q of %s is not declared before it", f.count, field.Name)
+	}
+	f.countField = count.Index[0]
+	return nil
+}
+
+// marshalRevision is the revision Marshal writes: the one set with
+// WithRevision, else the latest one the type declares.
+func (p *plan) marshalRevision(o *codecOptions) int {
+	if o.revision > 0 {
+		return o.revision
+	}
+	return p.latest
+}
+
+// in reports whether f exists in revision rev, where 0 means any.
+func (f *fieldPlan) in(rev int) bool {
+	if rev == 0 || f.minRev == 0 {
+		return true
+	}
+	return rev >= f.minRev && (f.maxRev == 0 || rev <= f.maxRev)
+}
+
+// length is the number of records of a repeated group or the number of
+// bytes of a binary block.
+func (f *fieldPlan) length(rv reflect.Value) (int, error) {
+	if f.kind == kindBinary && f.count == "" {
+		return f.end - f.start + 1, nil
+	}
+	n := f.fixedCount
+	if f.countField >= 0 {
+		n = int(rv.Field(f.countField).Int())
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("%s has negative count %d", f.name, n)
+	}
+	if f.kind == kindBinary {
+		return n * f.size, nil
+	}
+	return n, nil
+}
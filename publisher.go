@@ -7,7 +7,7 @@ import (
 type Publisher struct {
 	ch   chan []byte
 	done chan struct{}
-	wg   sync.WaitGroup
+	mu   sync.RWMutex
 	once sync.Once
 }
 
@@ -15,7 +15,7 @@ func NewPublisher() *Publisher {
 	return &Publisher{
 		ch:   make(chan []byte),
 		done: make(chan struct{}),
-		wg:   sync.WaitGroup{},
+		mu:   sync.RWMutex{},
 		once: sync.Once{},
 	}
 }
@@ -24,23 +24,29 @@ func (p *Publisher) Read() <-chan []byte {
 	return p.ch
 }
 
-func (p *Publisher) Write(data []byte) {
-	p.wg.Add(1)
-	defer p.wg.Done()
+// Write blocks until data is read or the publisher is closed and reports
+// whether data was read.
+func (p *Publisher) Write(data []byte) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
 	select {
 	case <-p.done:
+		return false
+	default:
+	}
+	select {
+	case <-p.done:
+		return false
 	case p.ch <- data:
+		return true
 	}
 }
 
 func (p *Publisher) Close() {
 	p.once.Do(func() {
 		close(p.done)
-		go func() {
-			for range p.ch {
-			}
-		}()
-		p.wg.Wait()
+		p.mu.Lock()
 		close(p.ch)
+		p.mu.Unlock()
 	})
 }
@@ -0,0 +1,105 @@
+package mid
+
+import (
+	"bytes"
+	"sync"
+)
+
+//go:generate go run ./cmd/midgen -spec mids.json -out .
+
+// registration is a type registered for a range of revisions of a MID. last
+// is 0 when the range is open.
+type registration struct {
+	first int
+	last  int
+	new   func() any
+}
+
+func (r registration) covers(revision int) bool {
+	return revision >= r.first && (r.last == 0 || revision <= r.last)
+}
+
+var registry = struct {
+	sync.RWMutex
+	types map[int][]registration
+}{
+	types: map[int][]registration{},
+}
+
+func init() {
+	Register[MID0004REV001](4, 1)
+	registerGenerated()
+}
+
+// Register makes Decode return a *T for messages with the given MID and
+// revision. Registering a pair again replaces the type.
+func Register[T any](mid, revision int) {
+	RegisterRange[T](mid, revision, revision)
+}
+
+// RegisterRange makes Decode return a *T for messages with the given MID and
+// a revision from first to last, or any revision from first on when last is
+// 0. It suits a type whose fields declare the revisions they exist in. Where
+// ranges overlap, the type registered last wins.
+func RegisterRange[T any](mid, first, last int) {
+	registry.Lock()
+	defer registry.Unlock()
+	regs := registry.types[mid]
+	for i, r := range regs {
+		if r.first == first && r.last == last {
+			regs = append(regs[:i], regs[i+1:]...)
+			break
+		}
+	}
+	registry.types[mid] = append(regs, registration{first: first, last: last, new: func() any { return new(T) }})
+}
+
+// Unregister removes every type registered for the MID and revision, so
+// Decode returns such messages as a *MID again.
+func Unregister(mid, revision int) {
+	registry.Lock()
+	defer registry.Unlock()
+	regs := registry.types[mid][:0]
+	for _, r := range registry.types[mid] {
+		if !r.covers(revision) {
+			regs = append(regs, r)
+		}
+	}
+	registry.types[mid] = regs
+}
+
+// New returns a pointer to a new value of the type registered for the MID
+// and revision. Revisions may lay out their data differently, so only a
+// type registered for the revision counts; revision 0 is revision 1.
+func New(mid, revision int) (any, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	if revision < 1 {
+		revision = 1
+	}
+	regs := registry.types[mid]
+	for i := len(regs) - 1; i >= 0; i-- {
+		if regs[i].covers(revision) {
+			return regs[i].new(), true
+		}
+	}
+	return nil, false
+}
+
+// Decode decodes a message into the type registered for its MID and
+// revision. A message of an unknown type is returned as a *MID.
+func Decode(data []byte, opts ...CodecOption) (any, error) {
+	data = bytes.TrimSuffix(data, []byte{'\x00'})
+	mid := &MID{}
+	if err := UnmarshalMID(data, mid, opts...); err != nil {
+		return nil, err
+	}
+	v, ok := New(mid.Header.MID, mid.Header.Revision)
+	if !ok {
+		return mid, nil
+	}
+	if err := Unmarshal(data, v, opts...); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
@@ -0,0 +1,28 @@
+package mid
+
+type ConnectionState int
+
+const (
+	StateConnected    ConnectionState = iota // Connection (re)established
+	StateDisconnected                        // Connection lost
+	StateClosed                              // Client closed for good
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case StateConnected:
+		return "connected"
+	case StateDisconnected:
+		return "disconnected"
+	case StateClosed:
+		return "closed"
+	}
+	return "unknown"
+}
+
+// ConnectionEvent reports a change of the connection state. Err holds the
+// reason a connection was lost.
+type ConnectionEvent struct {
+	State ConnectionState
+	Err   error
+}
@@ -0,0 +1,84 @@
+package mid
+
+import "sync"
+
+type AckPolicy int
+
+const (
+	// AckManual leaves acknowledging pushed data to the caller.
+	AckManual AckPolicy = iota
+	// AckOnReceive acknowledges pushed data as soon as it is received.
+	AckOnReceive
+	// AckOnConfirm acknowledges pushed data once the consumer confirms it:
+	// by Message.Ack on decoded subscriptions, by taking the frame off the
+	// channel on raw ones.
+	AckOnConfirm
+)
+
+type OverflowPolicy int
+
+const (
+	// OverflowBlock stops reading from the controller until the subscriber
+	// catches up. Command replies and keep-alive echoes wait as well.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest drops the oldest queued message to make room.
+	OverflowDropOldest
+	// OverflowFail closes the subscription channel and unsubscribes. It is
+	// the default.
+	OverflowFail
+)
+
+const defaultQueueSize = 16
+
+type subscription struct {
+	mid MID
+	// cancel is the MID ending the subscription, sent when it fails.
+	cancel    MID
+	keys      []string
+	publisher *Publisher
+	policy    AckPolicy
+	boltData  bool
+	decoded   bool
+	size      int
+	overflow  OverflowPolicy
+	queue     chan []byte
+	done      chan struct{}
+	once      sync.Once
+}
+
+func (s *subscription) close() {
+	s.once.Do(func() {
+		close(s.done)
+		s.publisher.Close()
+	})
+}
+
+type SubscribeOption func(s *subscription)
+
+func WithAck(policy AckPolicy) SubscribeOption {
+	return func(s *subscription) {
+		s.policy = policy
+	}
+}
+
+// WithBoltData asks for the bolt data (MID 0107) when a PowerMACS result is
+// acknowledged automatically.
+func WithBoltData(v bool) SubscribeOption {
+	return func(s *subscription) {
+		s.boltData = v
+	}
+}
+
+// WithQueue sets how many pushed messages are queued for a subscriber that
+// is not keeping up and what happens when the queue is full. By default 16
+// messages are queued and the subscription fails on overflow.
+func WithQueue(size int, overflow OverflowPolicy) SubscribeOption {
+	return func(s *subscription) {
+		s.size = size
+		s.overflow = overflow
+	}
+}
+
+func decode(s *subscription) {
+	s.decoded = true
+}